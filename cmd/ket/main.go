@@ -0,0 +1,134 @@
+// Command ket manages the dependencies of KET outside of go test.
+//
+//	ket bundle export [flags] <bundle.tar.gz>
+//	ket bundle import <bundle.tar.gz>
+//	ket lock [flags] [ket.lock]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+const usage = `Usage:
+  ket bundle export [flags] <bundle.tar.gz>  pack kind, kubectl, skaffold and the kindest/node image for an offline machine
+  ket bundle import <bundle.tar.gz>          unpack the bundle into the cache and load the kindest/node image
+  ket lock [flags] [ket.lock]                regenerate the lockfile
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "ket:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	switch {
+	case len(args) >= 2 && args[0] == "bundle" && args[1] == "export":
+		return exportBundle(ctx, args[2:])
+	case len(args) >= 2 && args[0] == "bundle" && args[1] == "import":
+		return importBundle(ctx, args[2:])
+	case len(args) >= 1 && args[0] == "lock":
+		return lock(ctx, args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %v", args)
+	}
+}
+
+// setupFlags defines the flags for the options of setup.
+func setupFlags(fs *flag.FlagSet) func() []setup.Option {
+	kindVersion := fs.String("kind-version", "", "version of kind (default: the default of setup)")
+	kubernetesVersion := fs.String("kubernetes-version", "", "version of kubectl and kindest/node (default: the default of setup)")
+	skaffoldVersion := fs.String("skaffold-version", "", "version of skaffold (default: the default of setup)")
+	useSkaffold := fs.Bool("skaffold", false, "include skaffold")
+	mirrorURL := fs.String("mirror-url", "", "download the binaries from the mirror")
+	return func() []setup.Option {
+		var options []setup.Option
+		if *kindVersion != "" {
+			options = append(options, setup.WithKindVersion(*kindVersion))
+		}
+		if *kubernetesVersion != "" {
+			options = append(options, setup.WithKubernetesVersion(*kubernetesVersion))
+		}
+		if *skaffoldVersion != "" {
+			options = append(options, setup.WithSkaffoldVersion(*skaffoldVersion))
+		}
+		if *useSkaffold {
+			options = append(options, setup.WithUseSkaffold())
+		}
+		if *mirrorURL != "" {
+			options = append(options, setup.WithMirrorURL(*mirrorURL))
+		}
+		return options
+	}
+}
+
+func exportBundle(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ket bundle export", flag.ContinueOnError)
+	goos := fs.String("os", runtime.GOOS, "os of the offline machine")
+	goarch := fs.String("arch", runtime.GOARCH, "architecture of the offline machine")
+	lockfile := fs.String("lockfile", "", "save the node image pinned in the lockfile")
+	options := setupFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("ket bundle export requires the path of the bundle")
+	}
+	opts := options()
+	if *lockfile != "" {
+		opts = append(opts, setup.WithLockfile(*lockfile))
+	}
+	return setup.ExportBundle(ctx, fs.Arg(0), cli.Platform{OS: *goos, Arch: *goarch}, opts...)
+}
+
+func importBundle(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ket bundle import", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("ket bundle import requires the path of the bundle")
+	}
+	return setup.ImportBundle(ctx, fs.Arg(0))
+}
+
+func lock(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ket lock", flag.ContinueOnError)
+	platforms := fs.String("platforms", runtime.GOOS+"/"+runtime.GOARCH, "comma separated platforms to lock, e.g. linux/amd64,darwin/arm64")
+	options := setupFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := setup.DefaultLockfile
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		path = fs.Arg(0)
+	default:
+		return fmt.Errorf("ket lock accepts only the path of the lockfile")
+	}
+
+	var locked []cli.Platform
+	for _, p := range strings.Split(*platforms, ",") {
+		i := strings.Index(p, "/")
+		if i <= 0 || i == len(p)-1 {
+			return fmt.Errorf("invalid platform %q, want os/arch", p)
+		}
+		locked = append(locked, cli.Platform{OS: p[:i], Arch: p[i+1:]})
+	}
+	return setup.WriteLockfile(ctx, path, locked, options()...)
+}
@@ -3,7 +3,11 @@ module github.com/riita10069/ket
 go 1.16
 
 require (
+	github.com/go-logr/logr v0.4.0
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	golang.org/x/sys v0.0.0-20210616094352-59db8d763f22
+	k8s.io/api v0.22.1
 	k8s.io/apimachinery v0.22.1
 	k8s.io/client-go v0.22.1
+	sigs.k8s.io/yaml v1.2.0
 )
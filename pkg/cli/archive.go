@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+type ArchiveFormat string
+
+const (
+	TarGz ArchiveFormat = "tar.gz"
+	Zip   ArchiveFormat = "zip"
+)
+
+// Archiver is an optional capability of CLI.
+// If a CLI implements it, URL points to an archive and Get extracts only the executable from it.
+// The checksum supplied by Checksummer is the one of the archive.
+type Archiver interface {
+	// ArchiveFormat returns an empty string if URL points to the executable itself.
+	ArchiveFormat() ArchiveFormat
+	// ArchiveMember returns the path of the executable in the archive, e.g. "linux-amd64/helm".
+	ArchiveMember() string
+}
+
+// extract writes the member of the archive to dst.
+func extract(format ArchiveFormat, archive, member, dst string) error {
+	member = cleanMember(member)
+	switch format {
+	case TarGz:
+		return extractTarGz(archive, member, dst)
+	case Zip:
+		return extractZip(archive, member, dst)
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+func extractTarGz(archive, member, dst string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s is not found in the archive", member)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || cleanMember(hdr.Name) != member {
+			continue
+		}
+		return writeExecutable(tr, dst)
+	}
+}
+
+func extractZip(archive, member, dst string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to read zip: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || cleanMember(f.Name) != member {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return writeExecutable(rc, dst)
+	}
+	return fmt.Errorf("%s is not found in the archive", member)
+}
+
+func writeExecutable(r io.Reader, dst string) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil { //nolint:gosec
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func cleanMember(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
@@ -0,0 +1,108 @@
+package cli_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+type fakeArchiveCLI struct {
+	fakeCLI
+	format cli.ArchiveFormat
+	member string
+}
+
+func (f *fakeArchiveCLI) ArchiveFormat() cli.ArchiveFormat { return f.format }
+func (f *fakeArchiveCLI) ArchiveMember() string            { return f.member }
+
+func Test_getArchive(t *testing.T) {
+	files := map[string]string{
+		"README.md":           "readme",
+		"linux-amd64/LICENSE": "license",
+		"linux-amd64/tool":    "binary",
+	}
+
+	tgz := new(bytes.Buffer)
+	gw := gzip.NewWriter(tgz)
+	tw := tar.NewWriter(gw)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0o755, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zb := new(bytes.Buffer)
+	zw := zip.NewWriter(zb)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archives := map[cli.ArchiveFormat][]byte{cli.TarGz: tgz.Bytes(), cli.Zip: zb.Bytes()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archives[cli.ArchiveFormat(r.URL.Path[1:])])
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		format  cli.ArchiveFormat
+		member  string
+		wantErr bool
+	}{
+		{name: "tar.gz", format: cli.TarGz, member: "linux-amd64/tool"},
+		{name: "zip", format: cli.Zip, member: "linux-amd64/tool"},
+		{name: "member is not found", format: cli.TarGz, member: "tool", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setCacheDir(t)
+			tool := &fakeArchiveCLI{
+				fakeCLI: fakeCLI{name: "tool", version: "1.0.0", dir: t.TempDir(), url: server.URL + "/" + string(tt.format), sha256: sha256Hex(archives[tt.format])},
+				format:  tt.format,
+				member:  tt.member,
+			}
+			err := cli.Get(context.Background(), tool)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			b, err := os.ReadFile(tool.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "binary" {
+				t.Errorf("extracted %q, want %q", b, "binary")
+			}
+		})
+	}
+}
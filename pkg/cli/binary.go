@@ -0,0 +1,41 @@
+package cli
+
+import "path/filepath"
+
+// Binary is a CLI for a binary which is already built, e.g. the controller under test.
+// Get never downloads it.
+type Binary struct {
+	path string
+	envs []string
+}
+
+func NewBinary(path string, envs ...string) *Binary {
+	return &Binary{
+		path: path,
+		envs: envs,
+	}
+}
+
+func (b *Binary) Name() string {
+	return filepath.Base(b.path)
+}
+
+func (b *Binary) Version() string {
+	return ""
+}
+
+func (b *Binary) Path() string {
+	return b.path
+}
+
+func (b *Binary) Dir() string {
+	return filepath.Dir(b.path)
+}
+
+func (b *Binary) URL() string {
+	return ""
+}
+
+func (b *Binary) Envs() []string {
+	return b.envs
+}
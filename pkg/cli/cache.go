@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// CacheDirEnv overrides the root directory of the binary cache.
+const CacheDirEnv = "KET_CACHE_DIR"
+
+// usedMarker is touched on every Get so that PruneCache knows which versions are still in use.
+const usedMarker = ".used"
+
+// CacheDir returns the root directory of the binary cache shared across repositories.
+// It is $KET_CACHE_DIR if set, otherwise $XDG_CACHE_HOME/ket (or the platform equivalent).
+func CacheDir() (string, error) {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "ket"), nil
+}
+
+// CachePath returns the path of the cached binary of cli,
+// i.e. <CacheDir>/<name>/<version>/<os>-<arch>/<name>.
+func CachePath(cli CLI) (string, error) {
+	root, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	platform := platformOf(cli)
+	return filepath.Join(root, cli.Name(), cli.Version(), platform.OS+"-"+platform.Arch, cli.Name()), nil
+}
+
+// PruneCache removes the cached binaries which haven't been used by Get for the duration.
+func PruneCache(unusedFor time.Duration) error {
+	root, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	platformDirs, err := filepath.Glob(filepath.Join(root, "*", "*", "*"))
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(-unusedFor)
+	for _, dir := range platformDirs {
+		info, err := os.Stat(filepath.Join(dir, usedMarker))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to stat %s: %w", dir, err)
+		}
+		if err == nil && info.ModTime().After(deadline) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+		// Remove the version directory too when no platform is left.
+		_ = os.Remove(filepath.Dir(dir))
+	}
+	return nil
+}
+
+func touchUsed(cached string) error {
+	marker := filepath.Join(filepath.Dir(cached), usedMarker)
+	now := time.Now()
+	err := os.Chtimes(marker, now, now)
+	if os.IsNotExist(err) {
+		f, err := os.Create(marker)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	return err
+}
+
+var linkSeq uint64
+
+// link makes path point at the cached binary.
+// It uses a symlink and falls back to a copy where symlinks are not available.
+func link(cached, path string) error {
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		if dest, err := os.Readlink(path); err == nil && dest == cached {
+			return nil
+		}
+	case info.Mode().IsRegular():
+		if isCopyOf(info, cached) {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Replace path with rename, which is atomic,
+	// so that the other processes never see a missing or half-written binary.
+	tmp := fmt.Sprintf("%s.%d-%d.tmp", path, os.Getpid(), atomic.AddUint64(&linkSeq, 1))
+	_ = os.Remove(tmp)
+	if err := os.Symlink(cached, tmp); err != nil {
+		if err := copyFile(cached, tmp); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// isCopyOf reports whether the regular file described by info was copied from cached by copyFile.
+func isCopyOf(info os.FileInfo, cached string) bool {
+	cachedInfo, err := os.Stat(cached)
+	if err != nil {
+		return false
+	}
+	return info.Size() == cachedInfo.Size() && info.ModTime().Equal(cachedInfo.ModTime())
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,112 @@
+package cli_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"golang.org/x/sync/errgroup"
+)
+
+func Test_getVersionedCache(t *testing.T) {
+	setCacheDir(t)
+	downloads := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads[r.URL.Path]++
+		_, _ = w.Write([]byte("binary " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	binDir := t.TempDir()
+	newTool := func(version string) *fakeCLI {
+		return &fakeCLI{name: "tool", version: version, dir: binDir, url: server.URL + "/" + version}
+	}
+
+	for _, version := range []string{"1.0.0", "2.0.0", "1.0.0", "2.0.0"} {
+		tool := newTool(version)
+		if err := cli.Get(context.Background(), tool); err != nil {
+			t.Fatalf("Get(%s) error = %v", version, err)
+		}
+		b, err := os.ReadFile(tool.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), "binary /"+version; got != want {
+			t.Errorf("%s contains %q, want %q", tool.Path(), got, want)
+		}
+	}
+
+	for path, n := range downloads {
+		if n != 1 {
+			t.Errorf("%s is downloaded %d times, want once", path, n)
+		}
+	}
+}
+
+func Test_PruneCache(t *testing.T) {
+	cacheDir := setCacheDir(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("binary"))
+	}))
+	defer server.Close()
+
+	binDir := t.TempDir()
+	oldTool := &fakeCLI{name: "tool", version: "1.0.0", dir: binDir, url: server.URL}
+	newTool := &fakeCLI{name: "tool", version: "2.0.0", dir: binDir, url: server.URL}
+	for _, tool := range []*fakeCLI{oldTool, newTool} {
+		if err := cli.Get(context.Background(), tool); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	oldCached, _ := cli.CachePath(oldTool)
+	if err := os.Chtimes(filepath.Join(filepath.Dir(oldCached), ".used"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.PruneCache(24 * time.Hour); err != nil {
+		t.Fatalf("PruneCache() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(cacheDir, "tool", "1.0.0")); !os.IsNotExist(err) {
+		t.Errorf("unused version is not pruned: %v", err)
+	}
+	newCached, _ := cli.CachePath(newTool)
+	if _, err := os.Stat(newCached); err != nil {
+		t.Errorf("used version is pruned: %v", err)
+	}
+}
+
+func Test_getConcurrently(t *testing.T) {
+	setCacheDir(t)
+	var mu sync.Mutex
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		downloads++
+		mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("binary"))
+	}))
+	defer server.Close()
+
+	binDir := t.TempDir()
+	var eg errgroup.Group
+	for i := 0; i < 5; i++ {
+		eg.Go(func() error {
+			return cli.Get(context.Background(), &fakeCLI{name: "tool", version: "1.0.0", dir: binDir, url: server.URL})
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if downloads != 1 {
+		t.Errorf("tool is downloaded %d times, want once", downloads)
+	}
+}
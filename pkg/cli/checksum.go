@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// artifactMarker records the SHA-256 of the downloaded file and of the cached binary, which differ if it is an archive.
+const artifactMarker = ".sha256"
+
+// Checksummer is an optional capability of CLI.
+// If a CLI implements it, Get verifies the SHA-256 of the downloaded binary.
+type Checksummer interface {
+	// SHA256 returns the pinned hex encoded digest.
+	// If it is empty, the digest is fetched from SHA256URL.
+	SHA256() string
+	// SHA256URL returns the URL of the checksum file published along with the binary.
+	SHA256URL() string
+}
+
+// ChecksumError is returned when the downloaded binary doesn't match the expected digest.
+type ChecksumError struct {
+	Name     string
+	Version  string
+	URL      string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("sha256 mismatch for %s %s downloaded from %s: expected %s, got %s", e.Name, e.Version, e.URL, e.Expected, e.Actual)
+}
+
+// expectedSHA256 returns the digest the binary of cli must have.
+// It returns an empty string if cli doesn't implement Checksummer.
+func expectedSHA256(ctx context.Context, cli CLI, config *DownloadConfig) (string, error) {
+	c, ok := cli.(Checksummer)
+	if !ok {
+		return "", nil
+	}
+	if sum := c.SHA256(); sum != "" {
+		return normalizeSHA256(sum)
+	}
+	if c.SHA256URL() == "" {
+		return "", nil
+	}
+	return fetchSHA256(ctx, config, config.resolve(c.SHA256URL()))
+}
+
+// fetchSHA256 downloads a checksum file such as "kubectl.sha256" or "kind-linux-amd64.sha256sum".
+// Both the bare digest and the sha256sum format "<digest>  <filename>" are accepted.
+func fetchSHA256(ctx context.Context, config *DownloadConfig, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize request: %w", err)
+	}
+	resp, err := config.do(req)
+	if err != nil {
+		return "", fmt.Errorf("url responses error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("url %s responses bad status: %s", url, resp.Status)
+	}
+
+	sum, err := parseSHA256(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum file %s: %w", url, err)
+	}
+	return sum, nil
+}
+
+func parseSHA256(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		return normalizeSHA256(fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no digest found")
+}
+
+func normalizeSHA256(sum string) (string, error) {
+	sum = strings.ToLower(strings.TrimSpace(sum))
+	b, err := hex.DecodeString(sum)
+	if err != nil || len(b) != 32 {
+		return "", fmt.Errorf("invalid sha256 digest %q", sum)
+	}
+	return sum, nil
+}
+
+// SHA256Of returns the hex encoded digest of the file at cli.URL(), e.g. to pin it in a lockfile.
+// The pinned or published digest is used if cli implements Checksummer, otherwise the file is downloaded and hashed.
+func SHA256Of(ctx context.Context, cli CLI) (string, error) {
+	config := downloadConfigOf(cli)
+	sum, err := expectedSHA256(ctx, cli, config)
+	if err != nil {
+		return "", fmt.Errorf("failed to get sha256 of %s: %w", cli.Name(), err)
+	}
+	if sum != "" {
+		return sum, nil
+	}
+
+	f, err := os.CreateTemp("", cli.Name()+"-*.part")
+	if err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	url := config.resolve(cli.URL())
+	if err := download(ctx, config, url, f.Name()); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return fileSHA256(f.Name())
+}
+
+// RecordArtifactSHA256 records that the cached binary comes from the file with the SHA-256, e.g. an archive.
+// ArtifactSHA256 returns it as long as the binary is unchanged.
+func RecordArtifactSHA256(cached, sum string) error {
+	binary, err := fileSHA256(cached)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", cached, err)
+	}
+	marker := filepath.Join(filepath.Dir(cached), artifactMarker)
+	if err := os.WriteFile(marker, []byte(sum+" "+binary+"\n"), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("failed to record sha256 of %s: %w", cached, err)
+	}
+	return nil
+}
+
+// ArtifactSHA256 returns the SHA-256 of the file the cached binary is downloaded from, e.g. to verify it against a lockfile.
+// It is the SHA-256 of the binary itself if it isn't recorded by RecordArtifactSHA256.
+// It fails if the binary has changed since it is recorded.
+func ArtifactSHA256(cached string) (string, error) {
+	binary, err := fileSHA256(cached)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", cached, err)
+	}
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(cached), artifactMarker))
+	if os.IsNotExist(err) {
+		return binary, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read sha256 of %s: %w", cached, err)
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 {
+		return "", fmt.Errorf("unexpected sha256 of %s: %q", cached, b)
+	}
+	if fields[1] != binary {
+		return "", fmt.Errorf("%s has changed since it is cached: sha256 was %s, got %s", cached, fields[1], binary)
+	}
+	return fields[0], nil
+}
@@ -0,0 +1,94 @@
+package cli_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+func Test_getChecksum(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho fake\n")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tool", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(binary)
+	})
+	mux.HandleFunc("/tool.sha256", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(sha256Hex(binary)))
+	})
+	mux.HandleFunc("/tool.sha256sum", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(sha256Hex(binary) + "  tool\n"))
+	})
+	mux.HandleFunc("/wrong.sha256", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(sha256Hex([]byte("other"))))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		sha256       string
+		sha256URL    string
+		wantMismatch bool
+	}{
+		{
+			name:      "published sha256 file",
+			sha256URL: server.URL + "/tool.sha256",
+		},
+		{
+			name:      "published sha256sum file",
+			sha256URL: server.URL + "/tool.sha256sum",
+		},
+		{
+			name:   "pinned digest",
+			sha256: sha256Hex(binary),
+		},
+		{
+			name:         "pinned digest mismatch",
+			sha256:       sha256Hex([]byte("other")),
+			wantMismatch: true,
+		},
+		{
+			name:         "published digest mismatch",
+			sha256URL:    server.URL + "/wrong.sha256",
+			wantMismatch: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setCacheDir(t)
+			tool := &fakeCLI{
+				name:      "tool",
+				version:   "1.0.0",
+				dir:       t.TempDir(),
+				url:       server.URL + "/tool",
+				sha256:    tt.sha256,
+				sha256URL: tt.sha256URL,
+			}
+			err := cli.Get(context.Background(), tool)
+
+			var checksumErr *cli.ChecksumError
+			if got := errors.As(err, &checksumErr); got != tt.wantMismatch {
+				t.Fatalf("Get() error = %v, wantMismatch %v", err, tt.wantMismatch)
+			}
+			if tt.wantMismatch {
+				if checksumErr.Name != "tool" || checksumErr.Version != "1.0.0" || checksumErr.URL != tool.URL() {
+					t.Errorf("ChecksumError doesn't describe the download: %+v", checksumErr)
+				}
+				if _, err := os.Stat(tool.Path()); !os.IsNotExist(err) {
+					t.Errorf("broken binary is left at %s", tool.Path())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package cli_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+type fakeCLI struct {
+	name      string
+	version   string
+	dir       string
+	url       string
+	sha256    string
+	sha256URL string
+	download  *cli.DownloadConfig
+	executor  cli.Executor
+	envs      []string
+	logging   *cli.Logging
+}
+
+func (f *fakeCLI) Name() string                        { return f.name }
+func (f *fakeCLI) Version() string                     { return f.version }
+func (f *fakeCLI) Path() string                        { return filepath.Join(f.dir, f.name) }
+func (f *fakeCLI) Dir() string                         { return f.dir }
+func (f *fakeCLI) URL() string                         { return f.url }
+func (f *fakeCLI) Envs() []string                      { return f.envs }
+func (f *fakeCLI) SHA256() string                      { return f.sha256 }
+func (f *fakeCLI) SHA256URL() string                   { return f.sha256URL }
+func (f *fakeCLI) DownloadConfig() *cli.DownloadConfig { return f.download }
+func (f *fakeCLI) Executor() cli.Executor              { return f.executor }
+func (f *fakeCLI) Logging() *cli.Logging               { return f.logging }
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// setCacheDir isolates the binary cache of the test.
+func setCacheDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	old, ok := os.LookupEnv(cli.CacheDirEnv)
+	if err := os.Setenv(cli.CacheDirEnv, dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(cli.CacheDirEnv, old)
+		} else {
+			_ = os.Unsetenv(cli.CacheDirEnv)
+		}
+	})
+	return dir
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DownloadConfig configures how Get downloads binaries and their checksum files.
+type DownloadConfig struct {
+	// HTTPClient is used for the downloads, e.g. one trusting the custom CA of a proxy.
+	// http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// MirrorURL replaces the scheme and host of the upstream URL with the upstream path preserved.
+	// e.g. with "https://mirror.example.com/ket", https://storage.googleapis.com/skaffold/releases/...
+	// is downloaded from https://mirror.example.com/ket/skaffold/releases/...
+	// file:// URLs are also supported.
+	MirrorURL string
+	// Headers are added to the requests to the host of the key, e.g. an Authorization header for "github.com".
+	// The headers of the key "" are added to the requests to every host.
+	Headers map[string]http.Header
+	// Policy decides whether the binary on PATH is used instead of downloading it.
+	// DownloadOnly is used if empty.
+	Policy ResolvePolicy
+	// VersionCheck decides when the version of the binary is verified.
+	// CheckAfterDownload is used if empty.
+	VersionCheck VersionCheck
+}
+
+// Downloader is an optional capability of CLI.
+// If a CLI implements it, Get downloads the binary with the returned configuration.
+type Downloader interface {
+	DownloadConfig() *DownloadConfig
+}
+
+var fileTransport = http.NewFileTransport(http.Dir("/"))
+
+func downloadConfigOf(cli CLI) *DownloadConfig {
+	if d, ok := cli.(Downloader); ok && d.DownloadConfig() != nil {
+		return d.DownloadConfig()
+	}
+	return &DownloadConfig{}
+}
+
+// resolve returns the URL which rawURL is actually downloaded from.
+func (c *DownloadConfig) resolve(rawURL string) string {
+	if c.MirrorURL == "" {
+		return rawURL
+	}
+	upstream, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	mirror, err := url.Parse(c.MirrorURL)
+	if err != nil {
+		return rawURL
+	}
+	mirror.Path = path.Join(mirror.Path, upstream.Path)
+	mirror.RawQuery = upstream.RawQuery
+	return mirror.String()
+}
+
+// do sends req, whose URL is already resolved, with the headers of its host.
+func (c *DownloadConfig) do(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "file" {
+		return fileTransport.RoundTrip(req)
+	}
+
+	for host, header := range c.Headers {
+		if host != "" && !strings.EqualFold(host, req.URL.Hostname()) {
+			continue
+		}
+		for key, values := range header {
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
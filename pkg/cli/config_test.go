@@ -0,0 +1,88 @@
+package cli_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+func Test_getDownloadConfig(t *testing.T) {
+	binary := []byte("binary")
+	var gotPath, gotToken, gotOther string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotToken = r.Header.Get("Authorization")
+		gotOther = r.Header.Get("X-Other-Host")
+		_, _ = w.Write(binary)
+	}))
+	defer mirror.Close()
+
+	mirrorDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(mirrorDir, "releases", "v1.0.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mirrorDir, "releases", "v1.0.0", "tool"), binary, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mirrorDir, _ = filepath.Abs(mirrorDir)
+
+	tests := []struct {
+		name      string
+		config    *cli.DownloadConfig
+		wantPath  string
+		wantToken string
+	}{
+		{
+			name: "http mirror with headers",
+			config: &cli.DownloadConfig{
+				HTTPClient: mirror.Client(),
+				MirrorURL:  mirror.URL + "/base",
+				Headers: map[string]http.Header{
+					"127.0.0.1":   {"Authorization": []string{"token secret"}},
+					"example.com": {"X-Other-Host": []string{"leaked"}},
+				},
+			},
+			wantPath:  "/base/releases/v1.0.0/tool",
+			wantToken: "token secret",
+		},
+		{
+			name: "file mirror",
+			config: &cli.DownloadConfig{
+				MirrorURL: "file://" + filepath.ToSlash(mirrorDir),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setCacheDir(t)
+			gotPath, gotToken, gotOther = "", "", ""
+			tool := &fakeCLI{
+				name:     "tool",
+				version:  "1.0.0",
+				dir:      t.TempDir(),
+				url:      "https://upstream.invalid/releases/v1.0.0/tool",
+				sha256:   sha256Hex(binary),
+				download: tt.config,
+			}
+			if err := cli.Get(context.Background(), tool); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("mirror is requested %q, want %q", gotPath, tt.wantPath)
+			}
+			if gotToken != tt.wantToken {
+				t.Errorf("Authorization = %q, want %q", gotToken, tt.wantToken)
+			}
+			if gotOther != "" {
+				t.Errorf("header for the other host is sent: %q", gotOther)
+			}
+		})
+	}
+}
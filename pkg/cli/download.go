@@ -2,48 +2,238 @@ package cli
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func get(ctx context.Context, cli CLI) error {
-	if err := os.MkdirAll(cli.Dir(), 0o755); err != nil {
-		return fmt.Errorf("can't create %s for %s dir: %w", cli.Dir(), cli.Name(), err)
+var (
+	downloadAttempts   = 5
+	downloadBackoff    = 1 * time.Second
+	downloadMaxBackoff = 30 * time.Second
+)
+
+// statusError is returned when the server responds with an unexpected status.
+type statusError struct {
+	url    string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("url %s responses bad status: %s", e.url, e.status)
+}
+
+// get downloads the binary of cli to dst.
+// The binary is written to dst.part and renamed to dst only when it is complete and verified,
+// so an interrupted download never leaves a truncated executable at dst.
+// A dst.part left by an interrupted download is resumed with a Range request.
+func get(ctx context.Context, cli CLI, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("can't create %s for %s dir: %w", filepath.Dir(dst), cli.Name(), err)
+	}
+
+	config := downloadConfigOf(cli)
+	url := config.resolve(cli.URL())
+
+	expected, err := expectedSHA256(ctx, cli, config)
+	if err != nil {
+		return fmt.Errorf("failed to get sha256 of %s: %w", cli.Name(), err)
+	}
+
+	part := dst + ".part"
+	backoff := downloadBackoff
+	for attempt := 1; ; attempt++ {
+		err = download(ctx, config, url, part)
+		if err == nil {
+			break
+		}
+		if attempt >= downloadAttempts || !isRetryable(err) {
+			return fmt.Errorf("failed to download %s after %d attempts: %w", url, attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > downloadMaxBackoff {
+			backoff = downloadMaxBackoff
+		}
+	}
+
+	actual, err := fileSHA256(part)
+	if err != nil {
+		return fmt.Errorf("failed to hash the downloaded file: %w", err)
+	}
+	if expected != "" && actual != expected {
+		// Start over next time instead of resuming the broken file.
+		_ = os.Remove(part)
+		return &ChecksumError{
+			Name:     cli.Name(),
+			Version:  cli.Version(),
+			URL:      url,
+			Expected: expected,
+			Actual:   actual,
+		}
+	}
+
+	if a, ok := cli.(Archiver); ok && a.ArchiveFormat() != "" {
+		extracted := dst + ".extract"
+		if err := extract(a.ArchiveFormat(), part, a.ArchiveMember(), extracted); err != nil {
+			_ = os.Remove(extracted)
+			_ = os.Remove(part)
+			return fmt.Errorf("failed to extract %s from %s: %w", a.ArchiveMember(), url, err)
+		}
+		_ = os.Remove(part)
+		part = extracted
 	}
 
-	out, err := os.Create(cli.Path())
+	return install(ctx, cli, part, dst, url, actual)
+}
+
+// install verifies the binary at part, which comes from source, and moves it to dst.
+// artifactSHA256 is the SHA-256 of the file the binary comes from, e.g. an archive.
+func install(ctx context.Context, cli CLI, part, dst, source, artifactSHA256 string) error {
+	err := os.Chmod(part, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to chmod when %s binary path: %w", cli.Name(), err)
+	}
+
+	version, err := verifyDownloaded(ctx, cli, part, source)
+	if err != nil {
+		_ = os.Remove(part)
+		return err
+	}
+
+	err = os.Rename(part, dst)
+	if err != nil {
+		return fmt.Errorf("failed to move the downloaded file into place: %w", err)
+	}
+	if err := RecordArtifactSHA256(dst, artifactSHA256); err != nil {
+		return err
+	}
+
+	if version != "" {
+		storeVerified(dst, version)
+		if err := os.WriteFile(filepath.Join(filepath.Dir(dst), versionMarker), []byte(version+"\n"), 0o644); err != nil { //nolint:gosec
+			return fmt.Errorf("failed to record the version of %s: %w", cli.Name(), err)
+		}
+	}
+	return nil
+}
+
+// download fetches url into part, resuming from the end of part if it already exists.
+func download(ctx context.Context, config *DownloadConfig, url, part string) error {
+	out, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
 		return fmt.Errorf("can't create download path: %w", err)
 	}
 	defer out.Close()
 
-	client := http.DefaultClient
-	req, err := http.NewRequest(http.MethodGet, cli.URL(), nil)
+	info, err := out.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to initialize request: %w", err)
+		return err
 	}
-	req = req.WithContext(ctx)
-	resp, err := client.Do(req)
+	offset := info.Size()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return fmt.Errorf("url responses error: %w", err)
+		return fmt.Errorf("failed to initialize request: %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("url responses bad status: %s", resp.Status)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
 
+	resp, err := config.do(req)
+	if err != nil {
+		return fmt.Errorf("url responses error: %w", err)
+	}
 	defer resp.Body.Close()
 
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored Range. Start over.
+		offset = 0
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			_ = out.Truncate(0)
+			return &statusError{url: url, status: "unexpected Content-Range " + resp.Header.Get("Content-Range"), code: resp.StatusCode}
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// part is longer than the file. Start over.
+		_ = out.Truncate(0)
+		return &statusError{url: url, status: resp.Status, code: resp.StatusCode}
+	default:
+		return &statusError{url: url, status: resp.Status, code: resp.StatusCode}
+	}
+
+	if err := out.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
 	_, err = io.Copy(out, resp.Body)
 	if err != nil {
 		return fmt.Errorf("can't write the downloaded file: %w", err)
 	}
+	return out.Close()
+}
 
-	err = os.Chmod(cli.Path(), 0o755)
+// contentRangeStart parses the first byte position of "bytes <start>-<end>/<size>".
+func contentRangeStart(contentRange string) (int64, bool) {
+	r := strings.TrimPrefix(contentRange, "bytes ")
+	i := strings.Index(r, "-")
+	if r == contentRange || i < 0 {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(r[:i], 10, 64)
 	if err != nil {
-		return fmt.Errorf("failed to chmod when kind binary path: %w", err)
+		return 0, false
 	}
+	return start, true
+}
 
-	return nil
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= http.StatusInternalServerError ||
+			se.code == http.StatusTooManyRequests ||
+			se.code == http.StatusRequestTimeout ||
+			se.code == http.StatusPartialContent ||
+			se.code == http.StatusRequestedRangeNotSatisfiable
+	}
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return false
+	}
+	// Connection errors and bodies shorter than Content-Length.
+	return true
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
@@ -0,0 +1,130 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+func Test_getRetryAndResume(t *testing.T) {
+	defer cli.SetDownloadBackoff(time.Millisecond)()
+	binary := bytes.Repeat([]byte("0123456789"), 1000)
+
+	tests := []struct {
+		name string
+		// failures responds to the first requests instead of serving the binary.
+		failures  []func(w http.ResponseWriter, r *http.Request)
+		wantRange []string
+		wantErr   bool
+	}{
+		{
+			name:      "no failure",
+			failures:  []func(w http.ResponseWriter, r *http.Request){},
+			wantRange: []string{""},
+		},
+		{
+			name: "resume after the connection is dropped",
+			failures: []func(w http.ResponseWriter, r *http.Request){
+				dropAfter(binary, 0, 4000),
+				dropAfter(binary, 4000, 3000),
+			},
+			wantRange: []string{"", "bytes=4000-", "bytes=7000-"},
+		},
+		{
+			name: "retry server error",
+			failures: []func(w http.ResponseWriter, r *http.Request){
+				func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+				func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTooManyRequests) },
+			},
+			wantRange: []string{"", "", ""},
+		},
+		{
+			name: "don't retry not found",
+			failures: []func(w http.ResponseWriter, r *http.Request){
+				func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			},
+			wantRange: []string{""},
+			wantErr:   true,
+		},
+		{
+			name: "give up after too many failures",
+			failures: []func(w http.ResponseWriter, r *http.Request){
+				dropAfter(binary, 0, 1),
+				dropAfter(binary, 1, 1),
+				dropAfter(binary, 2, 1),
+				dropAfter(binary, 3, 1),
+				dropAfter(binary, 4, 1),
+			},
+			wantRange: []string{"", "bytes=1-", "bytes=2-", "bytes=3-", "bytes=4-"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setCacheDir(t)
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if len(ranges) <= len(tt.failures) {
+					tt.failures[len(ranges)-1](w, r)
+					return
+				}
+				http.ServeContent(w, r, "tool", time.Time{}, bytes.NewReader(binary))
+			}))
+			defer server.Close()
+
+			tool := &fakeCLI{name: "tool", version: "1.0.0", dir: t.TempDir(), url: server.URL, sha256: sha256Hex(binary)}
+			err := cli.Get(context.Background(), tool)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(ranges) != len(tt.wantRange) {
+				t.Fatalf("got requests with Range %q, want %q", ranges, tt.wantRange)
+			}
+			for i := range ranges {
+				if ranges[i] != tt.wantRange[i] {
+					t.Errorf("got requests with Range %q, want %q", ranges, tt.wantRange)
+				}
+			}
+
+			cached, _ := cli.CachePath(tool)
+			if tt.wantErr {
+				if _, err := os.Stat(cached); !os.IsNotExist(err) {
+					t.Errorf("truncated binary is left at %s", cached)
+				}
+				return
+			}
+			b, err := os.ReadFile(tool.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, binary) {
+				t.Errorf("downloaded binary is broken: got %d bytes, want %d bytes", len(b), len(binary))
+			}
+		})
+	}
+}
+
+// dropAfter announces binary from offset but closes the connection after sending n bytes of it.
+func dropAfter(binary []byte, offset, n int) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(binary)-offset))
+		if offset > 0 {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(binary)-1, len(binary)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		_, _ = w.Write(binary[offset : offset+n])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+}
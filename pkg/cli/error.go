@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stderrTailSize is the size of the stderr kept in ExitError.
+const stderrTailSize = 4096
+
+// ExitError is returned by Run and Capture when the command exits with a non-zero status.
+type ExitError struct {
+	Name string
+	Args []string
+	// Env is CLI.Envs() with the values of secrets redacted.
+	Env      []string
+	ExitCode int
+	// Stderr is the tail of the stderr of the command.
+	Stderr string
+	Err    error
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("%s %s exited with %d", e.Name, strings.Join(e.Args, " "), e.ExitCode)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// tailBuffer keeps the last size bytes written to it.
+type tailBuffer struct {
+	size int
+	buf  []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.size; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Command is an invocation of a CLI passed to Executor.
+type Command struct {
+	CLI  CLI
+	Args []string
+	// Env is the whole environment of the process, i.e. os.Environ() and CLI.Envs().
+	Env []string
+	// Stdin is nil if the command reads nothing.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Executor executes the commands of CLIs.
+// It can be replaced to run the code built on the CLIs without the real binaries, e.g. in unit tests.
+type Executor interface {
+	Execute(ctx context.Context, cmd *Command) error
+}
+
+// Executable is an optional capability of CLI.
+// If a CLI implements it, Run executes the commands with the returned Executor.
+type Executable interface {
+	Executor() Executor
+}
+
+// Starter is an optional capability of Executor to start a command without waiting for it.
+// Start uses it to stop the command gracefully.
+// For the executors without it, the commands are executed in a goroutine and stopped by canceling their context.
+type Starter interface {
+	Start(ctx context.Context, cmd *Command) (Handle, error)
+}
+
+// Handle is a command started by Starter.
+type Handle interface {
+	Wait() error
+	Signal(sig os.Signal) error
+	Kill() error
+}
+
+// OSExecutor installs the binary with Get and executes it as a process. It is the default Executor.
+type OSExecutor struct{}
+
+func (OSExecutor) Execute(ctx context.Context, c *Command) error {
+	err := Get(ctx, c.CLI)
+	if err != nil {
+		return fmt.Errorf("failed to ensure %s: %w", c.CLI.Name(), err)
+	}
+	cmd := exec.CommandContext(ctx, c.CLI.Path(), c.Args...) //nolint:gosec
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	cmd.Stdin = c.Stdin
+	cmd.Env = c.Env
+	return cmd.Run()
+}
+
+// Start installs the binary with Get and starts it as a process.
+// Unlike Execute, ctx is used only for the installation, and the process is stopped through Handle.
+func (OSExecutor) Start(ctx context.Context, c *Command) (Handle, error) {
+	err := Get(ctx, c.CLI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure %s: %w", c.CLI.Name(), err)
+	}
+	cmd := exec.Command(c.CLI.Path(), c.Args...) //nolint:gosec
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	cmd.Stdin = c.Stdin
+	cmd.Env = c.Env
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &osHandle{cmd: cmd}, nil
+}
+
+type osHandle struct {
+	cmd *exec.Cmd
+}
+
+func (h *osHandle) Wait() error {
+	return h.cmd.Wait()
+}
+
+func (h *osHandle) Signal(sig os.Signal) error {
+	return h.cmd.Process.Signal(sig)
+}
+
+func (h *osHandle) Kill() error {
+	return h.cmd.Process.Kill()
+}
+
+// goHandle executes a command of an Executor without Starter in a goroutine.
+type goHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+func startInGoroutine(executor Executor, cmd *Command) Handle {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &goHandle{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(h.done)
+		h.err = executor.Execute(ctx, cmd)
+	}()
+	return h
+}
+
+func (h *goHandle) Wait() error {
+	<-h.done
+	h.cancel()
+	return h.err
+}
+
+func (h *goHandle) Signal(os.Signal) error {
+	h.cancel()
+	return nil
+}
+
+func (h *goHandle) Kill() error {
+	h.cancel()
+	return nil
+}
+
+func executorOf(cli CLI) Executor {
+	if e, ok := cli.(Executable); ok && e.Executor() != nil {
+		return e.Executor()
+	}
+	return OSExecutor{}
+}
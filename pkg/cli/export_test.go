@@ -0,0 +1,20 @@
+package cli
+
+import "time"
+
+// SetDownloadBackoff shortens the retry backoff in tests.
+func SetDownloadBackoff(backoff time.Duration) (restore func()) {
+	old := downloadBackoff
+	downloadBackoff = backoff
+	return func() {
+		downloadBackoff = old
+	}
+}
+
+// ResetSystemPaths forgets the binaries on PATH resolved by Get.
+func ResetSystemPaths() {
+	systemPaths.Range(func(key, _ interface{}) bool {
+		systemPaths.Delete(key)
+		return true
+	})
+}
@@ -3,16 +3,136 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 )
 
+// Get ensures the binary of cli is installed at cli.Path().
+// The binary is downloaded once into the versioned cache (see CachePath) and Path() is linked to it,
+// so changing the version of a CLI switches the binary without re-downloading the other versions.
+//
+// Depending on the ResolvePolicy, Path() is linked to the binary on PATH instead
+// when its version matches Version().
+//
+// The version of the downloaded binary is verified as configured by DownloadConfig.VersionCheck.
+//
+// If URL() is empty, Path() must already exist.
 func Get(ctx context.Context, cli CLI) error {
-	_, err := os.Stat(cli.Path())
-	if err == nil {
+	if cli.URL() == "" {
+		if _, err := os.Stat(cli.Path()); err != nil {
+			return fmt.Errorf("%s has no URL to download: %w", cli.Name(), err)
+		}
 		return nil
 	}
-	if err := get(ctx, cli); err != nil {
-		return fmt.Errorf("failed to download and install %s: %w", cli.Name(), err)
+
+	switch policy := downloadConfigOf(cli).Policy; policy {
+	case "", DownloadOnly:
+	case PreferSystem, Strict:
+		system, err := resolveSystem(ctx, cli)
+		if err == nil {
+			if err := link(system, cli.Path()); err != nil {
+				return fmt.Errorf("failed to link %s to %s: %w", cli.Path(), system, err)
+			}
+			return nil
+		}
+		if policy == Strict {
+			return fmt.Errorf("failed to find %s: %w", cli.Name(), err)
+		}
+	default:
+		return fmt.Errorf("unknown resolve policy %q", policy)
+	}
+
+	cached, err := Download(ctx, cli)
+	if err != nil {
+		return err
+	}
+	if err := link(cached, cli.Path()); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", cli.Path(), cached, err)
+	}
+	return verifyInstalled(ctx, cli, cached)
+}
+
+// Download ensures the binary of cli is in the cache and returns its path, without installing it at cli.Path().
+func Download(ctx context.Context, cli CLI) (string, error) {
+	cached, err := CachePath(cli)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve cache path of %s: %w", cli.Name(), err)
+	}
+
+	if _, err := os.Stat(cached); err != nil {
+		if err := getLocked(ctx, cli, cached, false); err != nil {
+			return "", fmt.Errorf("failed to download and install %s: %w", cli.Name(), err)
+		}
+	}
+
+	if err := touchUsed(cached); err != nil {
+		return "", fmt.Errorf("failed to mark %s as used: %w", cached, err)
+	}
+	return cached, nil
+}
+
+// Install installs the binary of cli read from r into the cache as Download does, e.g. to import it from a bundle.
+// The binary must have the SHA-256 sum, and its version is verified as configured by DownloadConfig.VersionCheck.
+// artifactSHA256 is the SHA-256 of the file the binary is downloaded from, e.g. an archive, which ArtifactSHA256 returns afterwards.
+// source tells where r comes from in the errors.
+func Install(ctx context.Context, cli CLI, r io.Reader, source, sum, artifactSHA256 string) error {
+	cached, err := CachePath(cli)
+	if err != nil {
+		return fmt.Errorf("failed to resolve cache path of %s: %w", cli.Name(), err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cached), 0o755); err != nil {
+		return fmt.Errorf("can't create %s for %s dir: %w", filepath.Dir(cached), cli.Name(), err)
+	}
+	unlock, err := lockFile(ctx, filepath.Join(filepath.Dir(cached), ".lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	part := cached + ".part"
+	if err := writeExecutable(r, part); err != nil {
+		_ = os.Remove(part)
+		return fmt.Errorf("failed to write %s: %w", part, err)
+	}
+	actual, err := fileSHA256(part)
+	if err != nil {
+		_ = os.Remove(part)
+		return fmt.Errorf("failed to hash %s: %w", part, err)
+	}
+	if actual != sum {
+		_ = os.Remove(part)
+		return &ChecksumError{
+			Name:     cli.Name(),
+			Version:  cli.Version(),
+			URL:      source,
+			Expected: sum,
+			Actual:   actual,
+		}
+	}
+	return install(ctx, cli, part, cached, source, artifactSHA256)
+}
+
+// getLocked downloads cli to cached while holding the lock of its version,
+// and reuses the result if another process has installed it while waiting for the lock.
+// If force is true, the cached binary is replaced unless another process has replaced it with the version meanwhile.
+func getLocked(ctx context.Context, cli CLI, cached string, force bool) error {
+	if err := os.MkdirAll(filepath.Dir(cached), 0o755); err != nil {
+		return fmt.Errorf("can't create %s for %s dir: %w", filepath.Dir(cached), cli.Name(), err)
+	}
+	unlock, err := lockFile(ctx, filepath.Join(filepath.Dir(cached), ".lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(cached); err == nil {
+		if !force || cachedVersionMatches(ctx, cli, cached) {
+			return nil
+		}
+		if err := os.Remove(cached); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", cached, err)
+		}
 	}
-	return nil
+	return get(ctx, cli, cached)
 }
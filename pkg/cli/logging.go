@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+)
+
+// Verbosity of the logs written by KET.
+const (
+	// LevelCommand is the level of the invocations of the tools.
+	LevelCommand = 1
+	// LevelOutput is the level of the verbose output of the tools.
+	LevelOutput = 2
+)
+
+// Logging configures where the invocations and the verbose output of the tools are reported.
+type Logging struct {
+	// Logger receives the invocations with the fields tool, args, duration and exitCode at LevelCommand,
+	// and the output of Execute at LevelOutput unless Output is set.
+	// A logger in the context passed to Run, e.g. one with the phase field, takes precedence.
+	Logger logr.Logger
+	// Output receives the output of Execute, e.g. a per-run log file.
+	Output io.Writer
+	// Transcript records the invocations with their start time and duration.
+	Transcript *Transcript
+}
+
+// Loggable is an optional capability of CLI.
+// If a CLI implements it, Run reports the invocations and Execute reports the output as configured.
+// Otherwise, Execute writes the output to os.Stdout and os.Stderr.
+type Loggable interface {
+	Logging() *Logging
+}
+
+func loggingOf(cli CLI) *Logging {
+	if l, ok := cli.(Loggable); ok && l.Logging() != nil {
+		return l.Logging()
+	}
+	return &Logging{}
+}
+
+// loggerOf returns the logger of the invocation, or nil if there is none.
+func loggerOf(ctx context.Context, cli CLI) logr.Logger {
+	if logger := logr.FromContext(ctx); logger != nil {
+		return logger
+	}
+	return loggingOf(cli).Logger
+}
+
+// Execute executes cli with args and reports the output as configured by Logging.
+func Execute(ctx context.Context, cli CLI, args []string) error {
+	return ExecuteWithStdin(ctx, cli, args, nil)
+}
+
+// ExecuteWithStdin is Execute feeding stdin to the command.
+func ExecuteWithStdin(ctx context.Context, cli CLI, args []string, stdin io.Reader) error {
+	l := loggingOf(cli)
+	switch {
+	case l.Output != nil:
+		return RunWithStdin(ctx, cli, args, stdin, l.Output, l.Output)
+	case loggerOf(ctx, cli) != nil:
+		fn := OutputLine(ctx, cli)
+		return streamWithStdin(ctx, cli, args, stdin, fn)
+	default:
+		return RunWithStdin(ctx, cli, args, stdin, os.Stdout, os.Stderr)
+	}
+}
+
+// OutputLine returns a function reporting the lines of the output of cli as Execute does,
+// e.g. for WithLineHandler of a background process.
+func OutputLine(ctx context.Context, cli CLI) func(line Line) {
+	l := loggingOf(cli)
+	logger := loggerOf(ctx, cli)
+	return func(line Line) {
+		switch {
+		case l.Output != nil:
+			fmt.Fprintln(l.Output, line.Text)
+		case logger != nil:
+			logger.V(LevelOutput).Info(line.Text, "tool", cli.Name(), "stream", line.Stream)
+		case line.Stream == Stderr:
+			fmt.Fprintln(os.Stderr, line.Text)
+		default:
+			fmt.Fprintln(os.Stdout, line.Text)
+		}
+	}
+}
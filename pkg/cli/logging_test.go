@@ -0,0 +1,100 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+)
+
+// recordLogger is a logr.Logger recording the messages with their level and fields.
+type recordLogger struct {
+	level   int
+	values  []interface{}
+	entries *[]string
+}
+
+func (l recordLogger) Enabled() bool { return true }
+
+func (l recordLogger) Info(msg string, keysAndValues ...interface{}) {
+	*l.entries = append(*l.entries, fmt.Sprintf("V(%d) %s %v", l.level, msg, append(l.values, keysAndValues...)))
+}
+
+func (l recordLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	*l.entries = append(*l.entries, fmt.Sprintf("error %s: %v %v", msg, err, append(l.values, keysAndValues...)))
+}
+
+func (l recordLogger) V(level int) logr.Logger {
+	l.level += level
+	return l
+}
+
+func (l recordLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	l.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return l
+}
+
+func (l recordLogger) WithName(name string) logr.Logger { return l }
+
+func TestExecuteLogger(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kind", "create", "cluster").Return("Creating cluster\n", "Ensuring node image\n", 0)
+	var entries []string
+	tool := &fakeCLI{
+		name:     "kind",
+		executor: fake,
+		logging:  &cli.Logging{Logger: recordLogger{entries: &entries}},
+	}
+
+	ctx := logr.NewContext(context.Background(), recordLogger{entries: &entries}.WithValues("phase", "create cluster"))
+	if err := cli.Execute(ctx, tool, []string{"create", "cluster"}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := []string{
+		"V(1) running command [phase create cluster tool kind args [create cluster]]",
+		"V(2) Creating cluster [phase create cluster tool kind stream stdout]",
+		"V(2) Ensuring node image [phase create cluster tool kind stream stderr]",
+		"V(1) finished command [phase create cluster tool kind args [create cluster] duration",
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("logged %d entries, want %d: %q", len(entries), len(want), entries)
+	}
+	// The order of stdout and stderr lines isn't guaranteed.
+	if entries[1] > entries[2] {
+		entries[1], entries[2] = entries[2], entries[1]
+	}
+	for i := range want {
+		if !strings.HasPrefix(entries[i], want[i]) {
+			t.Errorf("entries[%d] = %q, want %q", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestExecuteOutput(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kind", "create", "cluster").Return("Creating cluster\n", "", 0)
+	var entries []string
+	output := new(bytes.Buffer)
+	tool := &fakeCLI{
+		name:     "kind",
+		executor: fake,
+		logging:  &cli.Logging{Logger: recordLogger{entries: &entries}, Output: output},
+	}
+
+	if err := cli.Execute(context.Background(), tool, []string{"create", "cluster"}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if output.String() != "Creating cluster\n" {
+		t.Errorf("output = %q, want the output of the command", output.String())
+	}
+	if len(entries) != 2 {
+		t.Errorf("logged %q, want only the invocation", entries)
+	}
+}
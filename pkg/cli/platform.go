@@ -0,0 +1,32 @@
+package cli
+
+import "runtime"
+
+// Platform is the os and architecture the binaries are built for, e.g. linux/amd64.
+type Platform struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+}
+
+// HostPlatform returns the platform KET is running on.
+func HostPlatform() Platform {
+	return Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+}
+
+func (p Platform) String() string {
+	return p.OS + "/" + p.Arch
+}
+
+// Platformer is an optional capability of CLI.
+// If a CLI implements it, its binary is for the returned platform instead of the host,
+// e.g. to export a bundle for another machine. Such a binary is cached but never executed.
+type Platformer interface {
+	Platform() Platform
+}
+
+func platformOf(cli CLI) Platform {
+	if p, ok := cli.(Platformer); ok && p.Platform() != (Platform{}) {
+		return p.Platform()
+	}
+	return HostPlatform()
+}
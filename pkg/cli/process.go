@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultGracePeriod = 10 * time.Second
+	maxProcessLogs     = 1000
+)
+
+type ProcessOption func(*Process)
+
+// WithGracePeriod changes how long Stop waits after SIGTERM before SIGKILL. The default is 10 seconds.
+func WithGracePeriod(gracePeriod time.Duration) ProcessOption {
+	return func(p *Process) {
+		p.gracePeriod = gracePeriod
+	}
+}
+
+// WithRestartOnFailure restarts the command up to maxRestarts times when it exits with an error,
+// waiting backoff before each restart.
+func WithRestartOnFailure(maxRestarts int, backoff time.Duration) ProcessOption {
+	return func(p *Process) {
+		p.maxRestarts = maxRestarts
+		p.backoff = backoff
+	}
+}
+
+// WithLineHandler calls fn for every line of stdout and stderr as Stream does.
+func WithLineHandler(fn func(line Line)) ProcessOption {
+	return func(p *Process) {
+		p.onLine = fn
+	}
+}
+
+// Process is a command running in the background, e.g. skaffold dev or kubectl port-forward.
+type Process struct {
+	cli         CLI
+	args        []string
+	gracePeriod time.Duration
+	maxRestarts int
+	backoff     time.Duration
+	onLine      func(line Line)
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu       sync.Mutex
+	logs     []Line
+	restarts int
+	exitCode int
+	err      error
+}
+
+// processRun is a single run of the command of Process.
+type processRun struct {
+	handle Handle
+	tail   *tailBuffer
+	stdout *lineWriter
+	stderr *lineWriter
+}
+
+// Start starts cli with args in the background.
+// The command is stopped by Stop or when ctx is done, gracefully with SIGTERM.
+func Start(ctx context.Context, cli CLI, args []string, opts ...ProcessOption) (*Process, error) {
+	p := &Process{
+		cli:         cli,
+		args:        args,
+		gracePeriod: defaultGracePeriod,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		exitCode:    -1,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	r, err := p.start(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s %v: %w", cli.Name(), args, err)
+	}
+	go p.supervise(ctx, r)
+	return p, nil
+}
+
+func (p *Process) start(ctx context.Context) (*processRun, error) {
+	var mu sync.Mutex
+	r := &processRun{
+		tail:   &tailBuffer{size: stderrTailSize},
+		stdout: &lineWriter{stream: Stdout, mu: &mu, fn: p.handleLine},
+		stderr: &lineWriter{stream: Stderr, mu: &mu, fn: p.handleLine},
+	}
+	cmd := &Command{
+		CLI:    p.cli,
+		Args:   p.args,
+		Env:    append(os.Environ(), p.cli.Envs()...),
+		Stdout: r.stdout,
+		Stderr: io.MultiWriter(r.stderr, r.tail),
+	}
+
+	executor := executorOf(p.cli)
+	if s, ok := executor.(Starter); ok {
+		handle, err := s.Start(ctx, cmd)
+		if err != nil {
+			return nil, err
+		}
+		r.handle = handle
+		return r, nil
+	}
+	r.handle = startInGoroutine(executor, cmd)
+	return r, nil
+}
+
+func (p *Process) supervise(ctx context.Context, r *processRun) {
+	defer close(p.done)
+	for {
+		waitErr := make(chan error, 1)
+		go func(h Handle) {
+			waitErr <- h.Wait()
+		}(r.handle)
+
+		var err error
+		stopped := false
+		select {
+		case err = <-waitErr:
+		case <-p.stop:
+			stopped = true
+			err = p.terminate(r.handle, waitErr)
+		case <-ctx.Done():
+			stopped = true
+			err = p.terminate(r.handle, waitErr)
+		}
+		r.stdout.flush()
+		r.stderr.flush()
+		if err != nil {
+			err = wrapExecError(p.cli, p.args, r.tail, err)
+		}
+		p.exited(err, stopped)
+
+		if stopped || err == nil || p.Restarts() >= p.maxRestarts {
+			return
+		}
+
+		select {
+		case <-time.After(p.backoff):
+		case <-p.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+		r, err = p.start(ctx)
+		if err != nil {
+			p.exited(fmt.Errorf("failed to restart %s %v: %w", p.cli.Name(), p.args, err), false)
+			return
+		}
+		p.mu.Lock()
+		p.restarts++
+		p.exitCode = -1
+		p.err = nil
+		p.mu.Unlock()
+	}
+}
+
+// terminate sends SIGTERM and SIGKILL after the grace period.
+func (p *Process) terminate(h Handle, waitErr <-chan error) error {
+	if err := h.Signal(syscall.SIGTERM); err != nil {
+		_ = h.Kill()
+	}
+	select {
+	case err := <-waitErr:
+		return err
+	case <-time.After(p.gracePeriod):
+		_ = h.Kill()
+		return <-waitErr
+	}
+}
+
+func (p *Process) exited(err error, stopped bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.exitCode = 0
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		p.exitCode = exitErr.ExitCode
+	} else if err != nil {
+		p.exitCode = -1
+	}
+	// Exiting by Stop or ctx is not a failure.
+	if stopped {
+		err = nil
+	}
+	p.err = err
+}
+
+func (p *Process) handleLine(line Line) {
+	p.mu.Lock()
+	p.logs = append(p.logs, line)
+	if over := len(p.logs) - maxProcessLogs; over > 0 {
+		p.logs = append(p.logs[:0], p.logs[over:]...)
+	}
+	p.mu.Unlock()
+
+	if p.onLine != nil {
+		p.onLine(line)
+	}
+}
+
+// Done is closed when the command exits and won't be restarted.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the command to exit and returns the error unless it is stopped by Stop or ctx.
+func (p *Process) Wait() error {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Stop stops the command with SIGTERM, and SIGKILL after the grace period.
+// It returns the error if the command has already failed by itself.
+func (p *Process) Stop() error {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	return p.Wait()
+}
+
+// ExitCode returns the exit code of the last run, or -1 while it is running or if it is killed by a signal.
+func (p *Process) ExitCode() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exitCode
+}
+
+// Restarts returns how many times the command is restarted by WithRestartOnFailure.
+func (p *Process) Restarts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.restarts
+}
+
+// Logs returns the last lines of stdout and stderr.
+func (p *Process) Logs() []Line {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	logs := make([]Line, len(p.logs))
+	copy(logs, p.logs)
+	return logs
+}
@@ -0,0 +1,140 @@
+package cli_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+func writeScript(t *testing.T, script string) *cli.Binary {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tool is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	return cli.NewBinary(path)
+}
+
+func waitLine(t *testing.T, lines <-chan cli.Line, text string) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if line.Text == text {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("%q is not printed", text)
+		}
+	}
+}
+
+func TestStartAndStop(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		wantLog  string
+		wantCode int
+	}{
+		{
+			name:     "exit on SIGTERM",
+			script:   "trap 'echo terminated; exit 0' TERM\necho started\nwhile :; do sleep 0.1; done\n",
+			wantLog:  "terminated",
+			wantCode: 0,
+		},
+		{
+			name:     "SIGKILL after the grace period",
+			script:   "trap '' TERM\necho started\nwhile :; do sleep 0.1; done\n",
+			wantCode: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make(chan cli.Line, 100)
+			p, err := cli.Start(context.Background(), writeScript(t, tt.script), nil,
+				cli.WithGracePeriod(500*time.Millisecond),
+				cli.WithLineHandler(func(line cli.Line) { lines <- line }),
+			)
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			waitLine(t, lines, "started")
+
+			if err := p.Stop(); err != nil {
+				t.Errorf("Stop() error = %v", err)
+			}
+			select {
+			case <-p.Done():
+			default:
+				t.Error("Done() is not closed after Stop()")
+			}
+			if p.ExitCode() != tt.wantCode {
+				t.Errorf("ExitCode() = %d, want %d", p.ExitCode(), tt.wantCode)
+			}
+			if tt.wantLog != "" {
+				logs := p.Logs()
+				if len(logs) == 0 || logs[len(logs)-1].Text != tt.wantLog {
+					t.Errorf("Logs() = %+v, want the last line %q", logs, tt.wantLog)
+				}
+			}
+		})
+	}
+}
+
+func TestStartStoppedByContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err := cli.Start(ctx, writeScript(t, "while :; do sleep 0.1; done\n"), nil)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	cancel()
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait() error = %v, want nil for the cancellation", err)
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []cli.ProcessOption
+		wantRestarts int
+	}{
+		{name: "no restart"},
+		{name: "restart on failure", opts: []cli.ProcessOption{cli.WithRestartOnFailure(2, 10*time.Millisecond)}, wantRestarts: 2},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := cli.Start(context.Background(), writeScript(t, "echo boom >&2\nexit 3\n"), []string{"dev"}, tt.opts...)
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+
+			err = p.Wait()
+			var exitErr *cli.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode != 3 || !strings.Contains(exitErr.Stderr, "boom") {
+				t.Errorf("Wait() error = %v, want exit status 3 with stderr", err)
+			}
+			if p.Restarts() != tt.wantRestarts {
+				t.Errorf("Restarts() = %d, want %d", p.Restarts(), tt.wantRestarts)
+			}
+			if err := p.Stop(); err == nil {
+				t.Error("Stop() after the failure returns nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+var secretEnvPattern = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSW(OR)?D|CREDENTIAL|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|AUTH)`)
+
+// RedactEnv replaces the values of the environment variables which look like secrets, e.g. GITHUB_TOKEN.
+func RedactEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		i := strings.Index(kv, "=")
+		if i > 0 && secretEnvPattern.MatchString(kv[:i]) {
+			kv = kv[:i+1] + redacted
+		}
+		out = append(out, kv)
+	}
+	return out
+}
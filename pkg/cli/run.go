@@ -3,36 +3,129 @@ package cli
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"time"
 )
 
+// Run executes cli with args. The command reads nothing from stdin.
+// stdout and stderr may be nil to discard them.
+// If the command exits with a non-zero status, the error is *ExitError.
 func Run(ctx context.Context, cli CLI, args []string, stdout, stderr io.Writer) error {
-	err := Get(ctx, cli)
-	if err != nil {
-		return fmt.Errorf("failed to ensure %s: %w", cli.Name(), err)
+	return RunWithStdin(ctx, cli, args, nil, stdout, stderr)
+}
+
+// RunWithStdin executes cli with args feeding stdin, e.g. a manifest to kubectl apply -f -.
+func RunWithStdin(ctx context.Context, cli CLI, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	tail := &tailBuffer{size: stderrTailSize}
+	cmd := &Command{
+		CLI:    cli,
+		Args:   args,
+		Env:    append(os.Environ(), cli.Envs()...),
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: io.MultiWriter(stderr, tail),
 	}
-	cmd := exec.CommandContext(ctx, cli.Path(), args...) //nolint:gosec
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-	cmd.Stdin = os.Stdin
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, cli.Envs()...)
 
-	err = cmd.Run()
-	if err != nil {
-		return fmt.Errorf("failed to exec %v %s %v: %w", cmd.Env, cmd.Path, cmd.Args, err)
+	ctx, timeout, cancel := withTimeout(ctx, cli, args)
+	defer cancel()
+
+	logger := loggerOf(ctx, cli)
+	if logger != nil {
+		logger.V(LevelCommand).Info("running command", "tool", cli.Name(), "args", args)
+	}
+
+	started := time.Now()
+	err := executorOf(cli).Execute(ctx, cmd)
+	err = execError(ctx, cli, args, tail, timeout, started, err)
+	duration := time.Since(started)
+
+	exitCode := 0
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode
+	} else if err != nil {
+		exitCode = -1
+	}
+	if logger != nil {
+		logger.V(LevelCommand).Info("finished command", "tool", cli.Name(), "args", args, "duration", duration, "exitCode", exitCode)
 	}
-	return nil
+	if transcript := loggingOf(cli).Transcript; transcript != nil {
+		entry := TranscriptEntry{
+			Kind:     EntryCommand,
+			Name:     cli.Name(),
+			Args:     args,
+			Phase:    phaseFrom(ctx),
+			Start:    started,
+			Duration: duration,
+			ExitCode: exitCode,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		transcript.Record(entry)
+	}
+	return err
+}
+
+// execError converts err of the executor to *TimeoutError or *ExitError.
+func execError(ctx context.Context, cli CLI, args []string, tail *tailBuffer, timeout time.Duration, started time.Time, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// The deadline may come from the timeouts or ctx of the caller. Report it only if the timeout has expired.
+		if timeout > 0 && time.Since(started) < timeout {
+			timeout = 0
+		}
+		return &TimeoutError{
+			Name:    cli.Name(),
+			Args:    args,
+			Timeout: timeout,
+			Elapsed: time.Since(started),
+			Stderr:  tail.String(),
+			Err:     ctx.Err(),
+		}
+	}
+	return wrapExecError(cli, args, tail, err)
 }
 
+// wrapExecError converts err of the executor to *ExitError if the command exited with a non-zero status.
+func wrapExecError(cli CLI, args []string, stderrTail *tailBuffer, err error) error {
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return &ExitError{
+			Name:     cli.Name(),
+			Args:     args,
+			Env:      RedactEnv(cli.Envs()),
+			ExitCode: exitErr.ExitCode(),
+			Stderr:   stderrTail.String(),
+			Err:      err,
+		}
+	}
+	return fmt.Errorf("failed to exec %s %v: %w", cli.Name(), args, err)
+}
+
+// Capture executes cli with args and returns its stdout and stderr.
+// If the command exits with a non-zero status, the error wraps *ExitError.
 func Capture(ctx context.Context, cli CLI, args []string) (string, string, error) {
+	return CaptureWithStdin(ctx, cli, args, nil)
+}
+
+// CaptureWithStdin executes cli with args feeding stdin and returns its stdout and stderr.
+func CaptureWithStdin(ctx context.Context, cli CLI, args []string, stdin io.Reader) (string, string, error) {
 	outb := new(bytes.Buffer)
 	errb := new(bytes.Buffer)
 
-	err := Run(ctx, cli, args, outb, errb)
+	err := RunWithStdin(ctx, cli, args, stdin, outb, errb)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to execute command %s %v: %w", cli.Name(), args, err)
 	}
@@ -0,0 +1,41 @@
+package cli_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+)
+
+func TestRunExitError(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kubectl", "get", "pod", "ket").Return("", `Error from server (NotFound): pods "ket" not found`, 1)
+	tool := &fakeCLI{
+		name:     "kubectl",
+		executor: fake,
+		envs:     []string{"KUBECONFIG=./kubeconfig", "GITHUB_TOKEN=ghp_secret"},
+	}
+
+	_, _, err := cli.Capture(context.Background(), tool, []string{"get", "pod", "ket"})
+
+	var exitErr *cli.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Capture() error = %v, want *cli.ExitError", err)
+	}
+	if exitErr.Name != "kubectl" || exitErr.ExitCode != 1 || !reflect.DeepEqual(exitErr.Args, []string{"get", "pod", "ket"}) {
+		t.Errorf("ExitError doesn't describe the command: %+v", exitErr)
+	}
+	if !strings.Contains(exitErr.Stderr, "NotFound") {
+		t.Errorf("ExitError.Stderr = %q, want the stderr of the command", exitErr.Stderr)
+	}
+	if want := []string{"KUBECONFIG=./kubeconfig", "GITHUB_TOKEN=REDACTED"}; !reflect.DeepEqual(exitErr.Env, want) {
+		t.Errorf("ExitError.Env = %v, want %v", exitErr.Env, want)
+	}
+	if strings.Contains(err.Error(), "ghp_secret") {
+		t.Errorf("error leaks the secret: %v", err)
+	}
+}
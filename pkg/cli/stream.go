@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type StreamName string
+
+const (
+	Stdout StreamName = "stdout"
+	Stderr StreamName = "stderr"
+)
+
+// Line is a line written by a command.
+type Line struct {
+	Stream StreamName
+	Text   string
+	// Time is when the line is written.
+	Time time.Time
+}
+
+// Stream executes cli with args and calls fn for every line of stdout and stderr as it arrives,
+// e.g. for skaffold dev, kubectl logs -f or kubectl get -w.
+// fn is never called concurrently. To stop the command, e.g. once an expected line arrives, cancel ctx.
+// The command is bounded only by ctx, Timeouts.Deadline and Timeouts.Commands, not by Timeouts.Default.
+func Stream(ctx context.Context, cli CLI, args []string, fn func(line Line)) error {
+	return streamWithStdin(contextWithStreaming(ctx), cli, args, nil, fn)
+}
+
+func streamWithStdin(ctx context.Context, cli CLI, args []string, stdin io.Reader, fn func(line Line)) error {
+	var mu sync.Mutex
+	stdout := &lineWriter{stream: Stdout, mu: &mu, fn: fn}
+	stderr := &lineWriter{stream: Stderr, mu: &mu, fn: fn}
+
+	err := RunWithStdin(ctx, cli, args, stdin, stdout, stderr)
+	stdout.flush()
+	stderr.flush()
+	return err
+}
+
+// lineWriter calls fn for every line written to it.
+type lineWriter struct {
+	stream StreamName
+	mu     *sync.Mutex
+	fn     func(line Line)
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		text := string(bytes.TrimSuffix(w.buf[:i], []byte("\r")))
+		w.buf = w.buf[i+1:]
+		w.fn(Line{Stream: w.stream, Text: text, Time: now})
+	}
+	return len(p), nil
+}
+
+// flush calls fn for the last line without a newline.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) == 0 {
+		return
+	}
+	w.fn(Line{Stream: w.stream, Text: string(w.buf), Time: time.Now()})
+	w.buf = nil
+}
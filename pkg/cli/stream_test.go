@@ -0,0 +1,39 @@
+package cli_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+)
+
+func TestStream(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("skaffold", "dev").Return("Generating tags...\r\nDeployments stabilized in 3.2 seconds\npartial", "WARN: deprecated\n", 0)
+	tool := &fakeCLI{name: "skaffold", executor: fake}
+
+	var got []cli.Line
+	err := cli.Stream(context.Background(), tool, []string{"dev"}, func(line cli.Line) {
+		if line.Time.IsZero() {
+			t.Errorf("line %q has no time", line.Text)
+		}
+		line.Time = time.Time{}
+		got = append(got, line)
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	want := []cli.Line{
+		{Stream: cli.Stdout, Text: "Generating tags..."},
+		{Stream: cli.Stdout, Text: "Deployments stabilized in 3.2 seconds"},
+		{Stream: cli.Stderr, Text: "WARN: deprecated"},
+		{Stream: cli.Stdout, Text: "partial"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stream() lines = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ResolvePolicy decides whether Get uses the binary installed on PATH or downloads it.
+type ResolvePolicy string
+
+const (
+	// DownloadOnly always uses the downloaded binary. It is the default.
+	DownloadOnly ResolvePolicy = "download-only"
+	// PreferSystem uses the binary on PATH if its version matches, otherwise downloads it.
+	PreferSystem ResolvePolicy = "prefer-system"
+	// Strict requires the binary on PATH with the matching version and never downloads it.
+	Strict ResolvePolicy = "strict"
+)
+
+// VersionProber is an optional capability of CLI.
+// It is required to use the binary on PATH, whose version must be checked.
+type VersionProber interface {
+	// VersionArgs returns the arguments to print the version, e.g. []string{"version", "--client", "-o", "json"}.
+	VersionArgs() []string
+	// ParseVersion extracts the version from the stdout of VersionArgs.
+	ParseVersion(stdout string) (string, error)
+}
+
+// systemPaths memorizes the binaries on PATH resolved by Get
+// so that the version is not probed on every Run.
+var systemPaths sync.Map
+
+// resolveSystem returns the binary of cli on PATH whose version matches cli.Version().
+func resolveSystem(ctx context.Context, cli CLI) (string, error) {
+	key := cli.Name() + "@" + cli.Version() + "@" + cli.Path()
+	if path, ok := systemPaths.Load(key); ok {
+		return path.(string), nil
+	}
+
+	prober, ok := cli.(VersionProber)
+	if !ok || len(prober.VersionArgs()) == 0 {
+		return "", fmt.Errorf("%s can't probe its version", cli.Name())
+	}
+
+	var found []string
+	for _, path := range lookPathAll(cli) {
+		version, err := probeVersion(ctx, path, prober)
+		if err != nil {
+			found = append(found, fmt.Sprintf("%s (%v)", path, err))
+			continue
+		}
+		if sameVersion(version, cli.Version()) {
+			systemPaths.Store(key, path)
+			storeVerified(path, version)
+			return path, nil
+		}
+		found = append(found, fmt.Sprintf("%s (version %s)", path, version))
+	}
+	if len(found) == 0 {
+		return "", fmt.Errorf("%s is not found on PATH", cli.Name())
+	}
+	return "", fmt.Errorf("%s %s is not found on PATH: found %s", cli.Name(), cli.Version(), strings.Join(found, ", "))
+}
+
+// lookPathAll returns the executables named cli.Name() on PATH,
+// except the ones installed by Get itself.
+func lookPathAll(cli CLI) []string {
+	own, _ := filepath.Abs(cli.Path())
+	cacheDir, _ := CacheDir()
+
+	var paths []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		path, err := exec.LookPath(filepath.Join(dir, cli.Name()))
+		if err != nil {
+			continue
+		}
+		path, err = filepath.Abs(path)
+		if err != nil || path == own {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(path); err == nil && cacheDir != "" && strings.HasPrefix(real, cacheDir+string(filepath.Separator)) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func probeVersion(ctx context.Context, path string, prober VersionProber) (string, error) {
+	out, err := exec.CommandContext(ctx, path, prober.VersionArgs()...).Output() //nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("failed to get version: %w", err)
+	}
+	return prober.ParseVersion(string(out))
+}
+
+func sameVersion(a, b string) bool {
+	return strings.TrimPrefix(strings.TrimSpace(a), "v") == strings.TrimPrefix(strings.TrimSpace(b), "v")
+}
@@ -0,0 +1,92 @@
+package cli_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+type fakeProbedCLI struct {
+	fakeCLI
+}
+
+func (f *fakeProbedCLI) VersionArgs() []string { return []string{"version"} }
+func (f *fakeProbedCLI) ParseVersion(stdout string) (string, error) {
+	return strings.TrimPrefix(strings.TrimSpace(stdout), "tool version "), nil
+}
+
+func Test_getResolvePolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tool is a shell script")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("downloaded"))
+	}))
+	defer server.Close()
+
+	systemDir := t.TempDir()
+	script := "#!/bin/sh\necho tool version 1.0.0\n"
+	if err := os.WriteFile(filepath.Join(systemDir, "tool"), []byte(script), 0o755); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	oldPath := os.Getenv("PATH")
+	if err := os.Setenv("PATH", systemDir+string(os.PathListSeparator)+oldPath); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", oldPath)
+
+	tests := []struct {
+		name       string
+		policy     cli.ResolvePolicy
+		version    string
+		wantSystem bool
+		wantErr    bool
+	}{
+		{name: "download only", policy: cli.DownloadOnly, version: "1.0.0"},
+		{name: "prefer system", policy: cli.PreferSystem, version: "1.0.0", wantSystem: true},
+		{name: "prefer system but version mismatch", policy: cli.PreferSystem, version: "2.0.0"},
+		{name: "strict", policy: cli.Strict, version: "1.0.0", wantSystem: true},
+		{name: "strict but version mismatch", policy: cli.Strict, version: "2.0.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setCacheDir(t)
+			cli.ResetSystemPaths()
+			tool := &fakeProbedCLI{fakeCLI{
+				name:     "tool",
+				version:  tt.version,
+				dir:      t.TempDir(),
+				url:      server.URL,
+				download: &cli.DownloadConfig{Policy: tt.policy, VersionCheck: cli.CheckNever},
+			}}
+			err := cli.Get(context.Background(), tool)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			b, err := os.ReadFile(tool.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "downloaded"
+			if tt.wantSystem {
+				want = script
+			}
+			if string(b) != want {
+				t.Errorf("%s is %q, want %q", tool.Path(), b, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeouts bounds the invocations of CLIs.
+type Timeouts struct {
+	// Default bounds every invocation except Stream, which follows e.g. kubectl logs -f as long as ctx lasts.
+	// There is no timeout if it is zero.
+	Default time.Duration
+	// Commands overrides Default by the command line, e.g. "kind create cluster" or "kubectl apply".
+	// The longest matching command wins.
+	Commands map[string]time.Duration
+	// Deadline bounds every invocation regardless of the timeouts, e.g. the one derived from go test -timeout.
+	Deadline time.Time
+}
+
+// Timeouter is an optional capability of CLI.
+// If a CLI implements it, Run bounds every invocation by the returned Timeouts.
+type Timeouter interface {
+	Timeouts() *Timeouts
+}
+
+// TimeoutError is returned by Run and Capture when the command doesn't finish in time.
+type TimeoutError struct {
+	Name string
+	Args []string
+	// Timeout is the timeout applied to the invocation. It is zero if the deadline of ctx or Timeouts.Deadline is exceeded.
+	Timeout time.Duration
+	// Elapsed is how long the command was running.
+	Elapsed time.Duration
+	// Stderr is the tail of the stderr of the command.
+	Stderr string
+	// Err is context.DeadlineExceeded.
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("%s %s timed out after %s", e.Name, strings.Join(e.Args, " "), e.Timeout)
+	}
+	return fmt.Sprintf("%s %s exceeded the deadline after running for %s", e.Name, strings.Join(e.Args, " "), e.Elapsed.Round(time.Millisecond))
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// streamingKey marks the context of Stream, which isn't bounded by Timeouts.Default.
+type streamingKey struct{}
+
+func contextWithStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingKey{}, true)
+}
+
+func isStreaming(ctx context.Context) bool {
+	streaming, _ := ctx.Value(streamingKey{}).(bool)
+	return streaming
+}
+
+// timeout returns the timeout of the invocation of the CLI named name with args.
+// If useDefault is false, only the timeouts of Commands apply.
+func (t *Timeouts) timeout(name string, args []string, useDefault bool) time.Duration {
+	var timeout time.Duration
+	if useDefault {
+		timeout = t.Default
+	}
+	matched := -1
+	for command, d := range t.Commands {
+		fields := strings.Fields(command)
+		if len(fields) == 0 || fields[0] != name || len(fields)-1 > len(args) || len(fields) <= matched {
+			continue
+		}
+		if equalStrings(fields[1:], args[:len(fields)-1]) {
+			timeout = d
+			matched = len(fields)
+		}
+	}
+	return timeout
+}
+
+// withTimeout bounds ctx by the timeouts of cli.
+func withTimeout(ctx context.Context, cli CLI, args []string) (context.Context, time.Duration, context.CancelFunc) {
+	t, ok := cli.(Timeouter)
+	if !ok || t.Timeouts() == nil {
+		return ctx, 0, func() {}
+	}
+	timeouts := t.Timeouts()
+
+	cancels := []context.CancelFunc{}
+	if !timeouts.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, timeouts.Deadline)
+		cancels = append(cancels, cancel)
+	}
+	timeout := timeouts.timeout(cli.Name(), args, !isStreaming(ctx))
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		cancels = append(cancels, cancel)
+	}
+	return ctx, timeout, func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
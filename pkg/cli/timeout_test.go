@@ -0,0 +1,108 @@
+package cli_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+type timeoutBinary struct {
+	*cli.Binary
+	timeouts *cli.Timeouts
+}
+
+func (b *timeoutBinary) Timeouts() *cli.Timeouts { return b.timeouts }
+
+func TestRunTimeout(t *testing.T) {
+	script := "exec sleep \"$1\"\n"
+
+	tests := []struct {
+		name        string
+		timeouts    *cli.Timeouts
+		args        []string
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{
+			name:     "in time",
+			timeouts: &cli.Timeouts{Default: 5 * time.Second},
+			args:     []string{"0"},
+		},
+		{
+			name:        "default timeout",
+			timeouts:    &cli.Timeouts{Default: 100 * time.Millisecond},
+			args:        []string{"5"},
+			wantTimeout: 100 * time.Millisecond,
+			wantErr:     true,
+		},
+		{
+			name: "command timeout overrides default",
+			timeouts: &cli.Timeouts{
+				Default:  5 * time.Second,
+				Commands: map[string]time.Duration{"tool": 10 * time.Second, "tool 5": 100 * time.Millisecond},
+			},
+			args:        []string{"5"},
+			wantTimeout: 100 * time.Millisecond,
+			wantErr:     true,
+		},
+		{
+			name:     "deadline",
+			timeouts: &cli.Timeouts{Default: 5 * time.Second, Deadline: time.Now().Add(100 * time.Millisecond)},
+			args:     []string{"5"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tool := &timeoutBinary{Binary: writeScript(t, script), timeouts: tt.timeouts}
+			err := cli.Run(context.Background(), tool, tt.args, nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			var timeoutErr *cli.TimeoutError
+			if !errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Run() error = %v, want *cli.TimeoutError", err)
+			}
+			if timeoutErr.Timeout != tt.wantTimeout {
+				t.Errorf("TimeoutError.Timeout = %s, want %s", timeoutErr.Timeout, tt.wantTimeout)
+			}
+			if !strings.Contains(err.Error(), "tool "+strings.Join(tt.args, " ")) {
+				t.Errorf("error doesn't report the command: %v", err)
+			}
+		})
+	}
+}
+
+func TestStreamOutlivesDefaultTimeout(t *testing.T) {
+	script := "sleep 0.3\necho done\n"
+	tool := &timeoutBinary{Binary: writeScript(t, script), timeouts: &cli.Timeouts{Default: 100 * time.Millisecond}}
+
+	var lines []string
+	err := cli.Stream(context.Background(), tool, nil, func(line cli.Line) {
+		lines = append(lines, line.Text)
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "done" {
+		t.Errorf("Stream() lines = %v, want [done]", lines)
+	}
+
+	// An explicit timeout of the command still applies.
+	tool.timeouts.Commands = map[string]time.Duration{"tool": 100 * time.Millisecond}
+	err = cli.Stream(context.Background(), tool, nil, func(cli.Line) {})
+	var timeoutErr *cli.TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Errorf("Stream() error = %v, want *cli.TimeoutError", err)
+	}
+}
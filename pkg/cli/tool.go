@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"text/template"
+)
+
+// ToolSpec declares a tool such as helm, kustomize or kubeseal so that it is downloaded, cached and executed as kind, kubectl and skaffold are.
+// URL, SHA256URL and ArchiveMember are text/template with the fields OS, Arch and Version,
+// e.g. "https://get.helm.sh/helm-v{{.Version}}-{{.OS}}-{{.Arch}}.tar.gz".
+type ToolSpec struct {
+	Name    string
+	Version string
+	URL     string
+	// SHA256 pins the hex encoded digest of the file at URL.
+	SHA256 string
+	// SHA256URL is the checksum file published along with the file at URL. It is used if SHA256 is empty.
+	SHA256URL string
+	// ArchiveFormat is set if URL points to an archive, from which ArchiveMember is extracted.
+	ArchiveFormat ArchiveFormat
+	ArchiveMember string
+	Envs          []string
+	// VersionArgs are the arguments to print the version, e.g. []string{"version", "--short"}.
+	// It is required to use the tool installed on PATH.
+	VersionArgs []string
+	// VersionRegexp extracts the version from the output of VersionArgs by its first submatch.
+	// By default, the first semantic version in the output without build metadata is used.
+	VersionRegexp string
+}
+
+// defaultVersionRegexp matches e.g. "v3.6.3" and "4.2.0-rc.1". Build metadata such as "+gd506314" is not a part of the version.
+var defaultVersionRegexp = regexp.MustCompile(`v?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?)`)
+
+// Tool is a CLI built from a ToolSpec.
+type Tool struct {
+	spec          ToolSpec
+	binDir        string
+	url           string
+	sha256URL     string
+	archiveMember string
+	versionRegexp *regexp.Regexp
+	platform      Platform
+	logging       *Logging
+	timeouts      *Timeouts
+	executor      Executor
+	download      *DownloadConfig
+}
+
+type ToolOption func(*Tool)
+
+// WithToolDownloadConfig configures how the tool is downloaded.
+func WithToolDownloadConfig(config *DownloadConfig) ToolOption {
+	return func(t *Tool) {
+		t.download = config
+	}
+}
+
+// WithToolExecutor replaces the Executor of the commands, e.g. with a fake in unit tests.
+func WithToolExecutor(executor Executor) ToolOption {
+	return func(t *Tool) {
+		t.executor = executor
+	}
+}
+
+// WithToolTimeouts bounds every invocation of the commands.
+func WithToolTimeouts(timeouts *Timeouts) ToolOption {
+	return func(t *Tool) {
+		t.timeouts = timeouts
+	}
+}
+
+// WithToolLogging configures where the invocations and the output of the commands are reported.
+func WithToolLogging(logging *Logging) ToolOption {
+	return func(t *Tool) {
+		t.logging = logging
+	}
+}
+
+// WithToolPlatform downloads the tool for the platform instead of the host, e.g. to export a bundle.
+func WithToolPlatform(platform Platform) ToolOption {
+	return func(t *Tool) {
+		t.platform = platform
+	}
+}
+
+// NewTool renders the templates of spec for the platform, which is the host by default. The tool is installed in binDir.
+func NewTool(spec ToolSpec, binDir string, opts ...ToolOption) (*Tool, error) {
+	if spec.Name == "" {
+		return nil, fmt.Errorf("name of the tool is empty")
+	}
+	if spec.Version == "" {
+		return nil, fmt.Errorf("version of %s is empty", spec.Name)
+	}
+	if spec.URL == "" {
+		return nil, fmt.Errorf("url of %s is empty", spec.Name)
+	}
+	if spec.ArchiveFormat != "" && spec.ArchiveMember == "" {
+		return nil, fmt.Errorf("archive member of %s is empty", spec.Name)
+	}
+
+	t := &Tool{
+		spec:          spec,
+		binDir:        binDir,
+		versionRegexp: defaultVersionRegexp,
+		platform:      HostPlatform(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	var err error
+	if t.url, err = renderTemplate(spec, t.platform, "url", spec.URL); err != nil {
+		return nil, err
+	}
+	if t.sha256URL, err = renderTemplate(spec, t.platform, "sha256 url", spec.SHA256URL); err != nil {
+		return nil, err
+	}
+	if t.archiveMember, err = renderTemplate(spec, t.platform, "archive member", spec.ArchiveMember); err != nil {
+		return nil, err
+	}
+	if spec.VersionRegexp != "" {
+		if t.versionRegexp, err = regexp.Compile(spec.VersionRegexp); err != nil {
+			return nil, fmt.Errorf("invalid version regexp of %s: %w", spec.Name, err)
+		}
+	}
+	return t, nil
+}
+
+func renderTemplate(spec ToolSpec, platform Platform, field, text string) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(field).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template of %s: %w", field, spec.Name, err)
+	}
+	buf := new(bytes.Buffer)
+	err = tmpl.Execute(buf, struct {
+		OS      string
+		Arch    string
+		Version string
+	}{
+		OS:      platform.OS,
+		Arch:    platform.Arch,
+		Version: spec.Version,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s template of %s: %w", field, spec.Name, err)
+	}
+	return buf.String(), nil
+}
+
+func (t *Tool) Name() string {
+	return t.spec.Name
+}
+
+func (t *Tool) Version() string {
+	return t.spec.Version
+}
+
+func (t *Tool) Path() string {
+	return filepath.Join(t.binDir, t.spec.Name)
+}
+
+func (t *Tool) Dir() string {
+	return t.binDir
+}
+
+func (t *Tool) URL() string {
+	return t.url
+}
+
+func (t *Tool) Platform() Platform {
+	return t.platform
+}
+
+func (t *Tool) Envs() []string {
+	return t.spec.Envs
+}
+
+func (t *Tool) Logging() *Logging {
+	return t.logging
+}
+
+func (t *Tool) Timeouts() *Timeouts {
+	return t.timeouts
+}
+
+func (t *Tool) Executor() Executor {
+	return t.executor
+}
+
+func (t *Tool) DownloadConfig() *DownloadConfig {
+	return t.download
+}
+
+func (t *Tool) SHA256() string {
+	return t.spec.SHA256
+}
+
+func (t *Tool) SHA256URL() string {
+	return t.sha256URL
+}
+
+// ArchiveFormat returns an empty string if the URL points to the executable itself.
+func (t *Tool) ArchiveFormat() ArchiveFormat {
+	return t.spec.ArchiveFormat
+}
+
+func (t *Tool) ArchiveMember() string {
+	return t.archiveMember
+}
+
+// VersionArgs returns nil if the version can't be probed.
+func (t *Tool) VersionArgs() []string {
+	return t.spec.VersionArgs
+}
+
+func (t *Tool) ParseVersion(stdout string) (string, error) {
+	m := t.versionRegexp.FindStringSubmatch(stdout)
+	switch {
+	case m == nil:
+		return "", fmt.Errorf("unexpected output of %s %v: %q", t.spec.Name, t.spec.VersionArgs, stdout)
+	case len(m) > 1:
+		return m[1], nil
+	default:
+		return m[0], nil
+	}
+}
+
+// Execute reports the output as configured by WithToolLogging, or uses os.Stdout and os.Stderr.
+func (t *Tool) Execute(ctx context.Context, args []string) error {
+	return Execute(ctx, t, args)
+}
+
+// Capture execute command with returning outs as string.
+func (t *Tool) Capture(ctx context.Context, args []string) (stdout string, stderr string, err error) {
+	return Capture(ctx, t, args)
+}
+
+// Stream execute command with calling fn for every line of the outputs as it arrives.
+func (t *Tool) Stream(ctx context.Context, args []string, fn func(line Line)) error {
+	return Stream(ctx, t, args, fn)
+}
+
+// Start starts the command in the background, e.g. helm upgrade --wait.
+func (t *Tool) Start(ctx context.Context, args []string, opts ...ProcessOption) (*Process, error) {
+	return Start(ctx, t, args, opts...)
+}
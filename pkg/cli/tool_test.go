@@ -0,0 +1,105 @@
+package cli_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+func TestTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tool is a shell script")
+	}
+	binary := "#!/bin/sh\necho v3.6.3+gd506314\n"
+	member := fmt.Sprintf("%s-%s/helm", runtime.GOOS, runtime.GOARCH)
+	tgz := new(bytes.Buffer)
+	gw := gzip.NewWriter(tgz)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: member, Mode: 0o755, Size: int64(len(binary)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(binary)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := fmt.Sprintf("/helm-v3.6.3-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case archivePath:
+			_, _ = w.Write(tgz.Bytes())
+		case archivePath + ".sha256sum":
+			fmt.Fprintf(w, "%s  %s\n", sha256Hex(tgz.Bytes()), archivePath[1:])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	setCacheDir(t)
+
+	tool, err := cli.NewTool(cli.ToolSpec{
+		Name:          "helm",
+		Version:       "3.6.3",
+		URL:           server.URL + "/helm-v{{.Version}}-{{.OS}}-{{.Arch}}.tar.gz",
+		SHA256URL:     server.URL + "/helm-v{{.Version}}-{{.OS}}-{{.Arch}}.tar.gz.sha256sum",
+		ArchiveFormat: cli.TarGz,
+		ArchiveMember: "{{.OS}}-{{.Arch}}/helm",
+		VersionArgs:   []string{"version", "--short"},
+	}, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTool() error = %v", err)
+	}
+	if tool.URL() != server.URL+archivePath {
+		t.Errorf("URL() = %s, want %s", tool.URL(), server.URL+archivePath)
+	}
+
+	if err := cli.Get(context.Background(), tool); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	b, err := os.ReadFile(tool.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != binary {
+		t.Errorf("extracted %q, want %q", b, binary)
+	}
+	if version := cli.VerifiedVersion(tool); version != "3.6.3" {
+		t.Errorf("VerifiedVersion() = %q, want the version parsed from the output", version)
+	}
+}
+
+func TestNewToolInvalidSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		spec cli.ToolSpec
+	}{
+		{name: "no name", spec: cli.ToolSpec{Version: "1.0.0", URL: "https://example.com/tool"}},
+		{name: "no version", spec: cli.ToolSpec{Name: "tool", URL: "https://example.com/tool"}},
+		{name: "no url", spec: cli.ToolSpec{Name: "tool", Version: "1.0.0"}},
+		{name: "unknown field", spec: cli.ToolSpec{Name: "tool", Version: "1.0.0", URL: "https://example.com/{{.Platform}}/tool"}},
+		{name: "no archive member", spec: cli.ToolSpec{Name: "tool", Version: "1.0.0", URL: "https://example.com/tool.zip", ArchiveFormat: cli.Zip}},
+		{name: "invalid version regexp", spec: cli.ToolSpec{Name: "tool", Version: "1.0.0", URL: "https://example.com/tool", VersionRegexp: "("}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cli.NewTool(tt.spec, t.TempDir()); err == nil {
+				t.Errorf("NewTool() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Kinds of TranscriptEntry.
+const (
+	EntryPhase   = "phase"
+	EntryCommand = "command"
+	EntryWait    = "wait"
+)
+
+// TranscriptEntry is a setup phase, an invocation of a tool or a wait for resources.
+type TranscriptEntry struct {
+	// Kind is EntryPhase, EntryCommand or EntryWait.
+	Kind string `json:"kind"`
+	// Name is the name of the phase, e.g. "create cluster", the name of the tool, e.g. "kind",
+	// or what is waited for, e.g. "deployment/ket in default".
+	Name string `json:"name"`
+	// Args are the arguments of the command.
+	Args []string `json:"args,omitempty"`
+	// Phase is the phase the command or the wait is executed in.
+	Phase string    `json:"phase,omitempty"`
+	Start time.Time `json:"start"`
+	// Duration is marshaled in nanoseconds.
+	Duration time.Duration `json:"duration"`
+	// ExitCode is the exit status of the command, or -1 if it didn't exit, e.g. on timeout.
+	ExitCode int    `json:"exitCode,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Transcript keeps the setup phases and the invocations of the tools in memory
+// so that a slow setup can be analyzed after the run. It is safe for concurrent use.
+type Transcript struct {
+	mu      sync.Mutex
+	entries []TranscriptEntry
+}
+
+func NewTranscript() *Transcript {
+	return &Transcript{}
+}
+
+// Record appends the entry to the transcript.
+func (t *Transcript) Record(entry TranscriptEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = append(t.entries, entry)
+}
+
+// Entries returns the entries in the order they finished.
+func (t *Transcript) Entries() []TranscriptEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TranscriptEntry(nil), t.entries...)
+}
+
+// WriteJSON writes the entries as a JSON array.
+func (t *Transcript) WriteJSON(w io.Writer) error {
+	entries := t.Entries()
+	if entries == nil {
+		entries = []TranscriptEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// traceEvent is a complete event of the Chrome trace-event format.
+type traceEvent struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat"`
+	Phase     string                 `json:"ph"`
+	Timestamp int64                  `json:"ts"`
+	Duration  int64                  `json:"dur"`
+	PID       int                    `json:"pid"`
+	TID       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteTrace writes the entries in the Chrome trace-event format,
+// which can be opened with chrome://tracing or https://ui.perfetto.dev.
+// The commands are nested in the phases they are executed in.
+func (t *Transcript) WriteTrace(w io.Writer) error {
+	entries := t.Entries()
+	var origin time.Time
+	for _, e := range entries {
+		if origin.IsZero() || e.Start.Before(origin) {
+			origin = e.Start
+		}
+	}
+
+	events := make([]traceEvent, 0, len(entries))
+	for _, e := range entries {
+		event := traceEvent{
+			Name:      e.Name,
+			Category:  e.Kind,
+			Phase:     "X",
+			Timestamp: e.Start.Sub(origin).Microseconds(),
+			Duration:  e.Duration.Microseconds(),
+			PID:       1,
+			TID:       1,
+			Args:      map[string]interface{}{},
+		}
+		if e.Kind == EntryCommand {
+			event.Args["args"] = e.Args
+			event.Args["exitCode"] = e.ExitCode
+		}
+		if e.Phase != "" {
+			event.Args["phase"] = e.Phase
+		}
+		if e.Error != "" {
+			event.Args["error"] = e.Error
+		}
+		events = append(events, event)
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []traceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{
+		TraceEvents:     events,
+		DisplayTimeUnit: "ms",
+	})
+}
+
+type phaseKey struct{}
+
+// ContextWithPhase returns a context whose commands are recorded in the transcript as executed in the phase.
+func ContextWithPhase(ctx context.Context, phase string) context.Context {
+	return context.WithValue(ctx, phaseKey{}, phase)
+}
+
+func phaseFrom(ctx context.Context) string {
+	phase, _ := ctx.Value(phaseKey{}).(string)
+	return phase
+}
+
+// Wait runs fn, which polls with the commands of cli until something is ready, e.g. kubectl WaitCRDs,
+// and reports it with its duration as a setup phase is reported. It is recorded as EntryWait in the phase of ctx.
+func Wait(ctx context.Context, cli CLI, name string, fn func(ctx context.Context) error) error {
+	logger := loggerOf(ctx, cli)
+	if logger != nil {
+		logger.Info("waiting", "wait", name)
+	}
+
+	started := time.Now()
+	err := fn(ctx)
+	duration := time.Since(started)
+	if logger != nil {
+		if err != nil {
+			logger.Error(err, "failed waiting", "wait", name, "duration", duration)
+		} else {
+			logger.Info("finished waiting", "wait", name, "duration", duration)
+		}
+	}
+	if transcript := loggingOf(cli).Transcript; transcript != nil {
+		entry := TranscriptEntry{
+			Kind:     EntryWait,
+			Name:     name,
+			Phase:    phaseFrom(ctx),
+			Start:    started,
+			Duration: duration,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		transcript.Record(entry)
+	}
+	return err
+}
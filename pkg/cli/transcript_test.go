@@ -0,0 +1,89 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+)
+
+func TestTranscript(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kind", "create", "cluster").Return("", "", 0)
+	fake.Expect("kind", "get", "clusters").Return("", "ERROR: boom\n", 1)
+	transcript := cli.NewTranscript()
+	tool := &fakeCLI{
+		name:     "kind",
+		executor: fake,
+		logging:  &cli.Logging{Transcript: transcript},
+	}
+
+	started := time.Now()
+	ctx := cli.ContextWithPhase(context.Background(), "create cluster")
+	if err := cli.Run(ctx, tool, []string{"create", "cluster"}, nil, nil); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err := cli.Run(context.Background(), tool, []string{"get", "clusters"}, nil, nil); err == nil {
+		t.Fatalf("Run() error = nil, want the exit error")
+	}
+	transcript.Record(cli.TranscriptEntry{Kind: cli.EntryPhase, Name: "create cluster", Start: started, Duration: time.Since(started)})
+
+	entries := transcript.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("recorded %d entries, want 3: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Kind != cli.EntryCommand || e.Name != "kind" || e.Phase != "create cluster" ||
+		!reflect.DeepEqual(e.Args, []string{"create", "cluster"}) || e.ExitCode != 0 || e.Error != "" || e.Start.Before(started) {
+		t.Errorf("entries[0] = %+v, want kind create cluster in the phase", e)
+	}
+	if e := entries[1]; e.Phase != "" || e.ExitCode != 1 || e.Error == "" {
+		t.Errorf("entries[1] = %+v, want the failed kind get clusters", e)
+	}
+
+	var trace struct {
+		TraceEvents []struct {
+			Name     string                 `json:"name"`
+			Category string                 `json:"cat"`
+			Phase    string                 `json:"ph"`
+			TS       int64                  `json:"ts"`
+			Dur      int64                  `json:"dur"`
+			Args     map[string]interface{} `json:"args"`
+		} `json:"traceEvents"`
+	}
+	buf := new(bytes.Buffer)
+	if err := transcript.WriteTrace(buf); err != nil {
+		t.Fatalf("WriteTrace() error = %v", err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("WriteTrace() wrote invalid JSON: %v", err)
+	}
+	if len(trace.TraceEvents) != 3 {
+		t.Fatalf("traced %d events, want 3", len(trace.TraceEvents))
+	}
+	phase := trace.TraceEvents[2]
+	if phase.Name != "create cluster" || phase.Category != cli.EntryPhase || phase.Phase != "X" || phase.TS != 0 {
+		t.Errorf("phase event = %+v, want a complete event starting at 0", phase)
+	}
+	for _, e := range trace.TraceEvents[:2] {
+		if e.TS < phase.TS || e.TS+e.Dur > phase.TS+phase.Dur {
+			t.Errorf("command event %+v isn't nested in the phase %+v", e, phase)
+		}
+	}
+
+	buf.Reset()
+	if err := transcript.WriteJSON(buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var decoded []cli.TranscriptEntry
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v", err)
+	}
+	if len(decoded) != 3 || decoded[1].ExitCode != 1 {
+		t.Errorf("WriteJSON() = %s, want the entries", buf.String())
+	}
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VersionCheck decides when Get runs the version command of a binary to verify that it is Version().
+// It requires the CLI to implement VersionProber.
+type VersionCheck string
+
+const (
+	// CheckAfterDownload verifies the binary once it is downloaded, before it is moved into the cache. It is the default.
+	CheckAfterDownload VersionCheck = "after-download"
+	// CheckOnGet also verifies the installed binary on every Get, and downloads it again on mismatch.
+	// The result is memorized until the binary changes.
+	CheckOnGet VersionCheck = "on-get"
+	// CheckNever trusts the downloaded binary.
+	CheckNever VersionCheck = "never"
+)
+
+// versionMarker records the version of the cached binary verified after the download.
+const versionMarker = ".version"
+
+// VersionError is returned when the version command of a binary reports an unexpected version,
+// e.g. a mirror serves the wrong release.
+type VersionError struct {
+	Name     string
+	Path     string
+	URL      string
+	Expected string
+	Actual   string
+}
+
+func (e *VersionError) Error() string {
+	if e.URL != "" {
+		return fmt.Sprintf("%s downloaded from %s reports version %s, want %s", e.Name, e.URL, e.Actual, e.Expected)
+	}
+	return fmt.Sprintf("%s at %s reports version %s, want %s", e.Name, e.Path, e.Actual, e.Expected)
+}
+
+type verifiedVersion struct {
+	version string
+	size    int64
+	modTime time.Time
+}
+
+// verifiedVersions memorizes the versions of the binaries verified in this process, keyed by their real path.
+var verifiedVersions sync.Map
+
+// VerifiedVersion returns the version reported by the version command of the binary installed at cli.Path(),
+// or an empty string if it hasn't been verified by Get.
+func VerifiedVersion(cli CLI) string {
+	if version, ok := loadVerified(cli.Path()); ok {
+		return version
+	}
+	real, err := filepath.EvalSymlinks(cli.Path())
+	if err != nil {
+		return ""
+	}
+	// The binary may have been downloaded and verified by another process.
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(real), versionMarker))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func versionCheckOf(cli CLI) (VersionProber, VersionCheck) {
+	check := downloadConfigOf(cli).VersionCheck
+	if check == "" {
+		check = CheckAfterDownload
+	}
+	prober, ok := cli.(VersionProber)
+	if !ok || len(prober.VersionArgs()) == 0 {
+		return nil, CheckNever
+	}
+	if platformOf(cli) != HostPlatform() {
+		// The binary can't be executed.
+		return nil, CheckNever
+	}
+	return prober, check
+}
+
+// verifyDownloaded verifies the binary downloaded to path, which is the not yet renamed file.
+func verifyDownloaded(ctx context.Context, cli CLI, path, url string) (string, error) {
+	prober, check := versionCheckOf(cli)
+	if check == CheckNever {
+		return "", nil
+	}
+	version, err := probeVersion(ctx, path, prober)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify the version of %s downloaded from %s: %w", cli.Name(), url, err)
+	}
+	if !sameVersion(version, cli.Version()) {
+		return "", &VersionError{
+			Name:     cli.Name(),
+			Path:     path,
+			URL:      url,
+			Expected: cli.Version(),
+			Actual:   version,
+		}
+	}
+	return version, nil
+}
+
+// verifyInstalled verifies the binary at cli.Path() if CheckOnGet is configured.
+// If the cached binary reports another version, it is downloaded again.
+func verifyInstalled(ctx context.Context, cli CLI, cached string) error {
+	prober, check := versionCheckOf(cli)
+	if check != CheckOnGet {
+		return nil
+	}
+	if version, ok := loadVerified(cli.Path()); ok && sameVersion(version, cli.Version()) {
+		return nil
+	}
+	version, err := probeVersion(ctx, cli.Path(), prober)
+	if err == nil && sameVersion(version, cli.Version()) {
+		storeVerified(cli.Path(), version)
+		return nil
+	}
+
+	if err := getLocked(ctx, cli, cached, true); err != nil {
+		return fmt.Errorf("failed to download %s again: %w", cli.Name(), err)
+	}
+	if err := link(cached, cli.Path()); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", cli.Path(), cached, err)
+	}
+	return nil
+}
+
+// cachedVersionMatches reports whether the cached binary reports the version of cli.
+func cachedVersionMatches(ctx context.Context, cli CLI, cached string) bool {
+	prober, _ := versionCheckOf(cli)
+	if prober == nil {
+		return false
+	}
+	version, err := probeVersion(ctx, cached, prober)
+	return err == nil && sameVersion(version, cli.Version())
+}
+
+func storeVerified(path, version string) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return
+	}
+	verifiedVersions.Store(real, verifiedVersion{version: version, size: info.Size(), modTime: info.ModTime()})
+}
+
+// loadVerified returns the memorized version of the binary at path unless the binary has changed since.
+func loadVerified(path string) (string, bool) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	v, ok := verifiedVersions.Load(real)
+	if !ok {
+		return "", false
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return "", false
+	}
+	verified := v.(verifiedVersion)
+	if info.Size() != verified.size || !info.ModTime().Equal(verified.modTime) {
+		return "", false
+	}
+	return verified.version, true
+}
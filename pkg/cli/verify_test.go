@@ -0,0 +1,114 @@
+package cli_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+func Test_getVersionCheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tool is a shell script")
+	}
+	// The server serves the release of the version in the path, e.g. /1.0.0.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("#!/bin/sh\necho tool version " + r.URL.Path[1:] + "\n"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		check       cli.VersionCheck
+		served      string
+		wantErr     bool
+		wantVersion string
+	}{
+		{name: "after download", served: "1.0.0", wantVersion: "1.0.0"},
+		{name: "after download but version mismatch", served: "2.0.0", wantErr: true},
+		{name: "never", check: cli.CheckNever, served: "2.0.0"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setCacheDir(t)
+			tool := &fakeProbedCLI{fakeCLI{
+				name:     "tool",
+				version:  "1.0.0",
+				dir:      t.TempDir(),
+				url:      server.URL + "/" + tt.served,
+				download: &cli.DownloadConfig{VersionCheck: tt.check},
+			}}
+			err := cli.Get(context.Background(), tool)
+			var versionErr *cli.VersionError
+			if tt.wantErr {
+				if !errors.As(err, &versionErr) || versionErr.Actual != tt.served || versionErr.Expected != "1.0.0" {
+					t.Fatalf("Get() error = %v, want *cli.VersionError", err)
+				}
+				cached, err := cli.CachePath(tool)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := os.Stat(cached); !os.IsNotExist(err) {
+					t.Errorf("the wrong release is cached: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if version := cli.VerifiedVersion(tool); version != tt.wantVersion {
+				t.Errorf("VerifiedVersion() = %q, want %q", version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func Test_getVersionCheckOnGet(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tool is a shell script")
+	}
+	var downloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		_, _ = w.Write([]byte("#!/bin/sh\necho tool version 1.0.0\n"))
+	}))
+	defer server.Close()
+	setCacheDir(t)
+
+	tool := &fakeProbedCLI{fakeCLI{
+		name:     "tool",
+		version:  "1.0.0",
+		dir:      t.TempDir(),
+		url:      server.URL,
+		download: &cli.DownloadConfig{VersionCheck: cli.CheckOnGet},
+	}}
+	if err := cli.Get(context.Background(), tool); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	// Someone replaces the cached binary with another release.
+	cached, err := cli.CachePath(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cached, []byte("#!/bin/sh\necho tool version 0.9.0-replaced\n"), 0o755); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	if err := cli.Get(context.Background(), tool); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if downloads != 2 {
+		t.Errorf("downloaded %d times, want 2", downloads)
+	}
+	if version := cli.VerifiedVersion(tool); version != "1.0.0" {
+		t.Errorf("VerifiedVersion() = %q, want 1.0.0", version)
+	}
+}
@@ -0,0 +1,177 @@
+// Package kettest provides fakes to unit-test the code built on KET without the real binaries and cluster.
+package kettest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+// Any matches any argument in Expect.
+const Any = "\x00any"
+
+// ExitError is returned by FakeExecutor for an expectation with a non-zero exit code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Expectation is an invocation expected by FakeExecutor and its scripted result.
+type Expectation struct {
+	tool     string
+	args     []string
+	stdin    *string
+	stdout   string
+	stderr   string
+	exitCode int
+	err      error
+	called   bool
+}
+
+// Return scripts the result of the invocation.
+func (e *Expectation) Return(stdout, stderr string, exitCode int) *Expectation {
+	e.stdout = stdout
+	e.stderr = stderr
+	e.exitCode = exitCode
+	return e
+}
+
+// WithStdin makes the invocation match only when the command is fed stdin.
+func (e *Expectation) WithStdin(stdin string) *Expectation {
+	e.stdin = &stdin
+	return e
+}
+
+// ReturnError makes the invocation fail with err, e.g. as if the binary couldn't be installed.
+func (e *Expectation) ReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) matches(cmd *cli.Command, stdin string) bool {
+	if e.tool != cmd.CLI.Name() || len(e.args) != len(cmd.Args) {
+		return false
+	}
+	if e.stdin != nil && *e.stdin != stdin {
+		return false
+	}
+	for i, arg := range e.args {
+		if arg != Any && arg != cmd.Args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Expectation) String() string {
+	return e.tool + " " + strings.Join(e.args, " ")
+}
+
+// FakeExecutor is a cli.Executor which serves the scripted results without executing anything.
+// The invocations must be made in the order of Expect unless InAnyOrder is called.
+// Every expectation must be invoked by the end of the test.
+type FakeExecutor struct {
+	t            testing.TB
+	mu           sync.Mutex
+	expectations []*Expectation
+	anyOrder     bool
+}
+
+func NewFakeExecutor(t testing.TB) *FakeExecutor {
+	f := &FakeExecutor{t: t}
+	t.Cleanup(f.AssertDone)
+	return f
+}
+
+// Expect adds an expected invocation of the tool, e.g. Expect("kubectl", "apply", "-f", kettest.Any).
+func (f *FakeExecutor) Expect(tool string, args ...string) *Expectation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := &Expectation{tool: tool, args: args}
+	f.expectations = append(f.expectations, e)
+	return e
+}
+
+// InAnyOrder allows the expectations to be invoked in any order, e.g. by kubectl.ApplyAllManifest.
+func (f *FakeExecutor) InAnyOrder() *FakeExecutor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.anyOrder = true
+	return f
+}
+
+func (f *FakeExecutor) Execute(ctx context.Context, cmd *cli.Command) error {
+	var stdin []byte
+	if cmd.Stdin != nil {
+		b, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return err
+		}
+		stdin = b
+	}
+
+	e, err := f.next(cmd, string(stdin))
+	if err != nil {
+		f.t.Error(err)
+		return err
+	}
+	if e.err != nil {
+		return e.err
+	}
+	if _, err := io.WriteString(cmd.Stdout, e.stdout); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(cmd.Stderr, e.stderr); err != nil {
+		return err
+	}
+	if e.exitCode != 0 {
+		return &ExitError{Code: e.exitCode}
+	}
+	return nil
+}
+
+func (f *FakeExecutor) next(cmd *cli.Command, stdin string) (*Expectation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	invocation := cmd.CLI.Name() + " " + strings.Join(cmd.Args, " ")
+	for _, e := range f.expectations {
+		if e.called {
+			continue
+		}
+		if e.matches(cmd, stdin) {
+			e.called = true
+			return e, nil
+		}
+		if !f.anyOrder {
+			if e.stdin != nil && e.String() == invocation {
+				return nil, fmt.Errorf("unexpected stdin of %q: got %q, want %q", invocation, stdin, *e.stdin)
+			}
+			return nil, fmt.Errorf("unexpected invocation %q, want %q", invocation, e)
+		}
+	}
+	return nil, fmt.Errorf("unexpected invocation %q", invocation)
+}
+
+// AssertDone reports the expectations which are not invoked. It is called at the end of the test.
+func (f *FakeExecutor) AssertDone() {
+	f.t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, e := range f.expectations {
+		if !e.called {
+			f.t.Errorf("expected invocation %q is not made", e)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package kettest_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/kind"
+	"github.com/riita10069/ket/pkg/kubectl"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFakeExecutor(t *testing.T) {
+	ctx := context.Background()
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", kettest.Any)
+	fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", "kindest/node:v1.20.2", "--kubeconfig", kettest.Any)
+	fake.Expect("kubectl", "get", "namespace", kettest.Any).Return("'default kube-system'", "", 0)
+	fake.Expect("kubectl", "apply", "-k", "./crd").Return("", "error: no such file", 1)
+
+	k := kind.NewKind("0.11.0", "1.20.2", t.TempDir(), "./kubeconfig", kind.WithExecutor(fake))
+	if err := k.DeleteCluster(ctx, "ket"); err != nil {
+		t.Fatalf("DeleteCluster() error = %v", err)
+	}
+	if err := k.CreateCluster(ctx, "ket"); err != nil {
+		t.Fatalf("CreateCluster() error = %v", err)
+	}
+
+	kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(fake))
+	namespaces, err := kc.GetNamespacesList(ctx)
+	if err != nil {
+		t.Fatalf("GetNamespacesList() error = %v", err)
+	}
+	if want := []string{"default", "kube-system"}; !reflect.DeepEqual(namespaces, want) {
+		t.Errorf("GetNamespacesList() = %v, want %v", namespaces, want)
+	}
+
+	err = kc.ApplyKustomize(ctx, "./crd")
+	var exitErr *kettest.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Errorf("ApplyKustomize() error = %v, want exit status 1", err)
+	}
+}
+
+func TestFakeExecutorInAnyOrder(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t).InAnyOrder()
+	fake.Expect("kubectl", "apply", "-f", "a.yaml")
+	fake.Expect("kubectl", "apply", "-f", "b.yaml")
+
+	kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(fake))
+	if err := kc.ApplyAllManifest(context.Background(), []string{"b.yaml", "a.yaml"}, false); err != nil {
+		t.Fatalf("ApplyAllManifest() error = %v", err)
+	}
+}
+
+func TestFakeExecutorWithStdin(t *testing.T) {
+	ctx := context.Background()
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kubectl", "apply", "-f", "-").
+		WithStdin(`{"apiVersion":"v1","items":[{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"ket","creationTimestamp":null},"spec":{},"status":{}}],"kind":"List"}`)
+	fake.Expect("kubectl", "delete", "-f", "-").WithStdin("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ket\n")
+
+	kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(fake))
+	if err := kc.ApplyObjects(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ket"}}); err != nil {
+		t.Fatalf("ApplyObjects() error = %v", err)
+	}
+	if err := kc.DeleteManifest(ctx, []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ket\n")); err != nil {
+		t.Fatalf("DeleteManifest() error = %v", err)
+	}
+}
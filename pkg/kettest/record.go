@@ -0,0 +1,143 @@
+package kettest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+// Invocation is a recorded invocation of a CLI.
+type Invocation struct {
+	Tool string   `json:"tool"`
+	Args []string `json:"args"`
+	// Env is the redacted CLI.Envs().
+	Env      []string      `json:"env,omitempty"`
+	Stdin    *string       `json:"stdin,omitempty"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	ExitCode int           `json:"exitCode"`
+	Duration time.Duration `json:"duration"`
+	// Error is the error which is not an exit of the process, e.g. the binary couldn't be installed.
+	Error string `json:"error,omitempty"`
+}
+
+// Golden is the content of a golden file written by Recorder.
+type Golden struct {
+	Invocations []Invocation `json:"invocations"`
+}
+
+// Recorder is a cli.Executor which executes the commands with the next Executor
+// and writes every invocation to the golden file, which Replay serves later.
+type Recorder struct {
+	path string
+	next cli.Executor
+	mu   sync.Mutex
+	gold Golden
+}
+
+// NewRecorder records the invocations to the golden file at path.
+// If next is nil, cli.OSExecutor is used.
+func NewRecorder(path string, next cli.Executor) *Recorder {
+	if next == nil {
+		next = cli.OSExecutor{}
+	}
+	return &Recorder{path: path, next: next}
+}
+
+func (r *Recorder) Execute(ctx context.Context, cmd *cli.Command) error {
+	outb := new(bytes.Buffer)
+	errb := new(bytes.Buffer)
+	recorded := *cmd
+	var stdin *string
+	if cmd.Stdin != nil {
+		b, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return err
+		}
+		s := string(b)
+		stdin = &s
+		recorded.Stdin = bytes.NewReader(b)
+	}
+	recorded.Stdout = io.MultiWriter(cmd.Stdout, outb)
+	recorded.Stderr = io.MultiWriter(cmd.Stderr, errb)
+
+	started := time.Now()
+	err := r.next.Execute(ctx, &recorded)
+	invocation := Invocation{
+		Tool:     cmd.CLI.Name(),
+		Args:     cmd.Args,
+		Env:      cli.RedactEnv(cmd.CLI.Envs()),
+		Stdin:    stdin,
+		Stdout:   outb.String(),
+		Stderr:   errb.String(),
+		Duration: time.Since(started),
+	}
+	var exitErr interface{ ExitCode() int }
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		invocation.ExitCode = exitErr.ExitCode()
+	default:
+		invocation.ExitCode = -1
+		invocation.Error = err.Error()
+	}
+
+	// Save every time, because TestMain usually ends with os.Exit without running defers.
+	if saveErr := r.append(invocation); saveErr != nil {
+		return fmt.Errorf("failed to record %s: %w", r.path, saveErr)
+	}
+	return err
+}
+
+func (r *Recorder) append(invocation Invocation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gold.Invocations = append(r.gold.Invocations, invocation)
+
+	b, err := json.MarshalIndent(r.gold, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o600)
+}
+
+// Replay returns a FakeExecutor which serves the invocations recorded in the golden file at path
+// without executing anything.
+func Replay(t testing.TB, path string) *FakeExecutor {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	var gold Golden
+	if err := json.Unmarshal(b, &gold); err != nil {
+		t.Fatalf("failed to parse golden file %s: %v", path, err)
+	}
+
+	f := NewFakeExecutor(t)
+	for _, invocation := range gold.Invocations {
+		e := f.Expect(invocation.Tool, invocation.Args...)
+		if invocation.Stdin != nil {
+			e.WithStdin(*invocation.Stdin)
+		}
+		if invocation.Error != "" {
+			e.ReturnError(errors.New(invocation.Error))
+			continue
+		}
+		e.Return(invocation.Stdout, invocation.Stderr, invocation.ExitCode)
+	}
+	return f
+}
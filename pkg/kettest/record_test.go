@@ -0,0 +1,39 @@
+package kettest_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/kubectl"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	golden := filepath.Join(t.TempDir(), "testdata", "namespaces.json")
+	getNamespaces := func(t *testing.T, kc *kubectl.Kubectl) []string {
+		t.Helper()
+		namespaces, err := kc.GetNamespacesList(ctx)
+		if err != nil {
+			t.Fatalf("GetNamespacesList() error = %v", err)
+		}
+		return namespaces
+	}
+
+	t.Run("record", func(t *testing.T) {
+		// A real run would use kettest.NewRecorder(golden, nil) to execute kubectl against the cluster.
+		fake := kettest.NewFakeExecutor(t)
+		fake.Expect("kubectl", "get", "namespace", kettest.Any).Return("'default kube-system'", "", 0)
+		kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(kettest.NewRecorder(golden, fake)))
+		getNamespaces(t, kc)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(kettest.Replay(t, golden)))
+		if got, want := getNamespaces(t, kc), []string{"default", "kube-system"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetNamespacesList() = %v, want %v", got, want)
+		}
+	})
+}
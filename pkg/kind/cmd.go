@@ -3,6 +3,8 @@ package kind
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 )
 
 func (k *Kind) CreateCluster(ctx context.Context, clusterName string) error {
@@ -11,10 +13,19 @@ func (k *Kind) CreateCluster(ctx context.Context, clusterName string) error {
 		"cluster",
 		"--name",
 		clusterName,
-		"--image",
-		"kindest/node:v" + k.kubernetesVersion,
-		"--kubeconfig",
-		k.kubeConfigPath,
+	}
+	// --image overrides the images of all the nodes, so it is passed only if the nodes don't set theirs or the image is pinned.
+	if k.nodeImage != "" || !k.config.hasNodeImage() {
+		args = append(args, "--image", k.NodeImage())
+	}
+	args = append(args, "--kubeconfig", k.kubeConfigPath)
+	if k.config != nil {
+		path, err := k.writeConfig()
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+		args = append(args, "--config", path)
 	}
 
 	err := k.Execute(ctx, args)
@@ -40,3 +51,54 @@ func (k *Kind) DeleteCluster(ctx context.Context, clusterName string) error {
 	}
 	return nil
 }
+
+// GetClusters returns the names of the existing kind clusters.
+func (k *Kind) GetClusters(ctx context.Context) ([]string, error) {
+	args := []string{
+		"get",
+		"clusters",
+	}
+
+	stdout, _, err := k.Capture(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kind clusters: %w", err)
+	}
+	// "No kind clusters found." is written to stderr.
+	return strings.Fields(stdout), nil
+}
+
+// ExportKubeconfig writes the kubeconfig of the existing cluster, e.g. to reuse it.
+func (k *Kind) ExportKubeconfig(ctx context.Context, clusterName string) error {
+	args := []string{
+		"export",
+		"kubeconfig",
+		"--name",
+		clusterName,
+		"--kubeconfig",
+		k.kubeConfigPath,
+	}
+
+	err := k.Execute(ctx, args)
+	if err != nil {
+		return fmt.Errorf("failed to export kubeconfig of kind cluster: %w", err)
+	}
+	return nil
+}
+
+// writeConfig writes the configuration to a temporary file for --config and returns the path.
+func (k *Kind) writeConfig() (string, error) {
+	b, err := k.config.YAML()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "kind-config-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create kind config: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(b); err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write kind config: %w", err)
+	}
+	return f.Name(), nil
+}
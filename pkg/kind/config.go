@@ -0,0 +1,188 @@
+package kind
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// NodeRole is the role of a node of the cluster.
+type NodeRole string
+
+const (
+	ControlPlaneRole NodeRole = "control-plane"
+	WorkerRole       NodeRole = "worker"
+)
+
+// Cluster mirrors the configuration of kind (kind.x-k8s.io/v1alpha4), which is passed to kind create cluster --config.
+// See https://kind.sigs.k8s.io/docs/user/configuration/.
+type Cluster struct {
+	// Nodes are the nodes of the cluster. A single control-plane node is created if it is empty.
+	Nodes      []Node      `json:"nodes,omitempty"`
+	Networking *Networking `json:"networking,omitempty"`
+	// FeatureGates are enabled or disabled in every component, e.g. {"EphemeralContainers": true}.
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+	// RuntimeConfig is passed to --runtime-config of the API server, e.g. {"api/alpha": "false"}.
+	RuntimeConfig map[string]string `json:"runtimeConfig,omitempty"`
+	// KubeadmConfigPatches are the patches to the kubeadm config of every node.
+	KubeadmConfigPatches []string `json:"kubeadmConfigPatches,omitempty"`
+}
+
+// Node is a node of the cluster.
+type Node struct {
+	Role NodeRole `json:"role"`
+	// Image overrides the node image, e.g. to run different Kubernetes versions.
+	// It is ignored if the image is set by WithNodeImage or pinned by the lockfile.
+	Image  string            `json:"image,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Taints are registered by kubeadm. They replace the default taint of the control-plane node.
+	Taints               []Taint       `json:"-"`
+	ExtraPortMappings    []PortMapping `json:"extraPortMappings,omitempty"`
+	ExtraMounts          []Mount       `json:"extraMounts,omitempty"`
+	KubeadmConfigPatches []string      `json:"kubeadmConfigPatches,omitempty"`
+}
+
+// Taint is a taint of a node, e.g. {Key: "dedicated", Value: "infra", Effect: "NoSchedule"}.
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+// PortMapping exposes a port of the node container on the host.
+type PortMapping struct {
+	ContainerPort int32  `json:"containerPort"`
+	HostPort      int32  `json:"hostPort,omitempty"`
+	ListenAddress string `json:"listenAddress,omitempty"`
+	// Protocol is TCP, UDP or SCTP. TCP is used if empty.
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// Mount mounts a path of the host into the node container.
+type Mount struct {
+	HostPath       string `json:"hostPath"`
+	ContainerPath  string `json:"containerPath"`
+	ReadOnly       bool   `json:"readOnly,omitempty"`
+	SelinuxRelabel bool   `json:"selinuxRelabel,omitempty"`
+	// Propagation is None, HostToContainer or Bidirectional.
+	Propagation string `json:"propagation,omitempty"`
+}
+
+// Networking configures the network of the cluster.
+type Networking struct {
+	// IPFamily is ipv4, ipv6 or dual.
+	IPFamily         string `json:"ipFamily,omitempty"`
+	APIServerAddress string `json:"apiServerAddress,omitempty"`
+	APIServerPort    int32  `json:"apiServerPort,omitempty"`
+	PodSubnet        string `json:"podSubnet,omitempty"`
+	ServiceSubnet    string `json:"serviceSubnet,omitempty"`
+	// DisableDefaultCNI doesn't install kindnet so that another CNI such as Calico can be installed.
+	DisableDefaultCNI bool `json:"disableDefaultCNI,omitempty"`
+	// KubeProxyMode is iptables, ipvs or none.
+	KubeProxyMode string `json:"kubeProxyMode,omitempty"`
+}
+
+// hasNodeImage reports whether any node sets its image.
+func (c *Cluster) hasNodeImage() bool {
+	if c == nil {
+		return false
+	}
+	for _, node := range c.Nodes {
+		if node.Image != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// YAML renders the configuration for kind create cluster --config.
+func (c *Cluster) YAML() ([]byte, error) {
+	rendered := *c
+	rendered.Nodes = make([]Node, 0, len(c.Nodes))
+	for _, node := range c.Nodes {
+		switch node.Role {
+		case ControlPlaneRole, WorkerRole:
+		default:
+			return nil, fmt.Errorf("unknown role %q of node", node.Role)
+		}
+		if len(node.Taints) > 0 {
+			patches, err := taintPatches(node)
+			if err != nil {
+				return nil, err
+			}
+			node.KubeadmConfigPatches = append(append([]string{}, node.KubeadmConfigPatches...), patches...)
+		}
+		rendered.Nodes = append(rendered.Nodes, node)
+	}
+
+	b, err := yaml.Marshal(struct {
+		Kind       string `json:"kind"`
+		APIVersion string `json:"apiVersion"`
+		Cluster    `json:",inline"`
+	}{
+		Kind:       "Cluster",
+		APIVersion: "kind.x-k8s.io/v1alpha4",
+		Cluster:    rendered,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render kind config: %w", err)
+	}
+	return b, nil
+}
+
+// taintPatches registers the taints of the node with kubeadm.
+// The first control-plane node is initialized with InitConfiguration, and the other nodes join with JoinConfiguration.
+func taintPatches(node Node) ([]string, error) {
+	kinds := []string{"JoinConfiguration"}
+	if node.Role == ControlPlaneRole {
+		kinds = append(kinds, "InitConfiguration")
+	}
+	patches := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		b, err := yaml.Marshal(map[string]interface{}{
+			"kind": kind,
+			"nodeRegistration": map[string]interface{}{
+				"taints": node.Taints,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render taints: %w", err)
+		}
+		patches = append(patches, string(b))
+	}
+	return patches, nil
+}
+
+// ControlPlane are the extra args of the control-plane components, e.g. {"enable-admission-plugins": "NodeRestriction,PodSecurityPolicy"}.
+// The keys are the flags without the leading dashes.
+type ControlPlane struct {
+	APIServer         map[string]string
+	ControllerManager map[string]string
+	Scheduler         map[string]string
+}
+
+// IsZero reports whether no extra args are set.
+func (c ControlPlane) IsZero() bool {
+	return len(c.APIServer) == 0 && len(c.ControllerManager) == 0 && len(c.Scheduler) == 0
+}
+
+// Patch renders the extra args as a patch to the kubeadm ClusterConfiguration, e.g. for Cluster.KubeadmConfigPatches.
+func (c ControlPlane) Patch() (string, error) {
+	patch := map[string]interface{}{
+		"kind": "ClusterConfiguration",
+	}
+	for key, args := range map[string]map[string]string{
+		"apiServer":         c.APIServer,
+		"controllerManager": c.ControllerManager,
+		"scheduler":         c.Scheduler,
+	} {
+		if len(args) > 0 {
+			patch[key] = map[string]interface{}{"extraArgs": args}
+		}
+	}
+	b, err := yaml.Marshal(patch)
+	if err != nil {
+		return "", fmt.Errorf("failed to render extra args of control plane: %w", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,138 @@
+package kind_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/kind"
+)
+
+func TestClusterYAML(t *testing.T) {
+	config := kind.Cluster{
+		Nodes: []kind.Node{
+			{
+				Role:              kind.ControlPlaneRole,
+				ExtraPortMappings: []kind.PortMapping{{ContainerPort: 30080, HostPort: 8080}},
+			},
+			{
+				Role:        kind.WorkerRole,
+				Labels:      map[string]string{"ingress-ready": "true"},
+				Taints:      []kind.Taint{{Key: "dedicated", Value: "ingress", Effect: "NoSchedule"}},
+				ExtraMounts: []kind.Mount{{HostPath: "./testdata", ContainerPath: "/data", ReadOnly: true}},
+			},
+		},
+		Networking:    &kind.Networking{IPFamily: "ipv4", PodSubnet: "10.240.0.0/16", DisableDefaultCNI: true},
+		FeatureGates:  map[string]bool{"EphemeralContainers": true},
+		RuntimeConfig: map[string]string{"api/alpha": "false"},
+	}
+
+	b, err := config.YAML()
+	if err != nil {
+		t.Fatalf("YAML() error = %v", err)
+	}
+	want := `apiVersion: kind.x-k8s.io/v1alpha4
+featureGates:
+  EphemeralContainers: true
+kind: Cluster
+networking:
+  disableDefaultCNI: true
+  ipFamily: ipv4
+  podSubnet: 10.240.0.0/16
+nodes:
+- extraPortMappings:
+  - containerPort: 30080
+    hostPort: 8080
+  role: control-plane
+- extraMounts:
+  - containerPath: /data
+    hostPath: ./testdata
+    readOnly: true
+  kubeadmConfigPatches:
+  - |
+    kind: JoinConfiguration
+    nodeRegistration:
+      taints:
+      - effect: NoSchedule
+        key: dedicated
+        value: ingress
+  labels:
+    ingress-ready: "true"
+  role: worker
+runtimeConfig:
+  api/alpha: "false"
+`
+	if string(b) != want {
+		t.Errorf("YAML() = %s\nwant %s", b, want)
+	}
+}
+
+func TestClusterYAMLUnknownRole(t *testing.T) {
+	config := kind.Cluster{Nodes: []kind.Node{{Role: "master"}}}
+	if _, err := config.YAML(); err == nil {
+		t.Error("YAML() error = nil, want the unknown role")
+	}
+}
+
+func TestCreateClusterWithConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodeImage string
+		nodes     []kind.Node
+		wantArgs  []string
+	}{
+		{
+			name:     "default image",
+			nodes:    []kind.Node{{Role: kind.ControlPlaneRole}, {Role: kind.WorkerRole}},
+			wantArgs: []string{"--image", "kindest/node:v1.20.2"},
+		},
+		{
+			name:  "image of node",
+			nodes: []kind.Node{{Role: kind.ControlPlaneRole}, {Role: kind.WorkerRole, Image: "kindest/node:v1.19.1"}},
+		},
+		{
+			name:      "pinned image overrides image of node",
+			nodeImage: "kindest/node:v1.20.2@sha256:98cf5288864662e37115e362b23e4369c8c4a408f99cbc06e58ac30ddc721600",
+			nodes:     []kind.Node{{Role: kind.ControlPlaneRole, Image: "kindest/node:v1.19.1"}},
+			wantArgs:  []string{"--image", "kindest/node:v1.20.2@sha256:98cf5288864662e37115e362b23e4369c8c4a408f99cbc06e58ac30ddc721600"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fake := kettest.NewFakeExecutor(t)
+			args := append([]string{"create", "cluster", "--name", "ket"}, tt.wantArgs...)
+			fake.Expect("kind", append(args, "--kubeconfig", "./kubeconfig", "--config", kettest.Any)...)
+			k := kind.NewKind("0.11.0", "1.20.2", t.TempDir(), "./kubeconfig",
+				kind.WithExecutor(fake),
+				kind.WithNodeImage(tt.nodeImage),
+				kind.WithConfig(&kind.Cluster{Nodes: tt.nodes}),
+			)
+
+			if err := k.CreateCluster(context.Background(), "ket"); err != nil {
+				t.Errorf("CreateCluster() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestControlPlanePatch(t *testing.T) {
+	patch, err := kind.ControlPlane{
+		APIServer: map[string]string{"enable-admission-plugins": "NodeRestriction,PodSecurityPolicy"},
+		Scheduler: map[string]string{"v": "4"},
+	}.Patch()
+	if err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+	want := `apiServer:
+  extraArgs:
+    enable-admission-plugins: NodeRestriction,PodSecurityPolicy
+kind: ClusterConfiguration
+scheduler:
+  extraArgs:
+    v: "4"
+`
+	if patch != want {
+		t.Errorf("Patch() = %s\nwant %s", patch, want)
+	}
+}
@@ -3,9 +3,8 @@ package kind
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
-	"runtime"
+	"strings"
 
 	"github.com/riita10069/ket/pkg/cli"
 )
@@ -17,17 +16,90 @@ type Kind struct {
 	binDir            string
 	url               string
 	kubeConfigPath    string
+	logging           *cli.Logging
+	timeouts          *cli.Timeouts
+	executor          cli.Executor
+	download          *cli.DownloadConfig
+	platform          cli.Platform
+	nodeImage         string
+	config            *Cluster
+	sha256            string
 }
 
-func NewKind(kindVersion, kubernetesVersion, binDir, kubeConfigPath string) *Kind {
-	return &Kind{
+type Option func(*Kind)
+
+// WithSHA256 pins the expected SHA-256 of the binary instead of fetching the published checksum file.
+func WithSHA256(sum string) Option {
+	return func(k *Kind) {
+		k.sha256 = sum
+	}
+}
+
+// WithDownloadConfig configures how the binary is downloaded.
+func WithDownloadConfig(config *cli.DownloadConfig) Option {
+	return func(k *Kind) {
+		k.download = config
+	}
+}
+
+// WithPlatform downloads the binary for the platform instead of the host, e.g. to export a bundle.
+func WithPlatform(platform cli.Platform) Option {
+	return func(k *Kind) {
+		k.platform = platform
+	}
+}
+
+// WithNodeImage creates the cluster with the node image, e.g. one pinned by digest.
+// If it is empty, kindest/node tagged with the Kubernetes version is used unless a node of WithConfig sets its image.
+// It overrides the images of all the nodes of WithConfig.
+func WithNodeImage(image string) Option {
+	return func(k *Kind) {
+		k.nodeImage = image
+	}
+}
+
+// WithConfig creates the cluster with the configuration, e.g. multiple nodes or extra port mappings.
+func WithConfig(config *Cluster) Option {
+	return func(k *Kind) {
+		k.config = config
+	}
+}
+
+// WithExecutor replaces the Executor of the commands, e.g. with a fake in unit tests.
+func WithExecutor(executor cli.Executor) Option {
+	return func(k *Kind) {
+		k.executor = executor
+	}
+}
+
+// WithTimeouts bounds every invocation of the commands.
+func WithTimeouts(timeouts *cli.Timeouts) Option {
+	return func(k *Kind) {
+		k.timeouts = timeouts
+	}
+}
+
+// WithLogging configures where the invocations and the output of the commands are reported.
+func WithLogging(logging *cli.Logging) Option {
+	return func(k *Kind) {
+		k.logging = logging
+	}
+}
+
+func NewKind(kindVersion, kubernetesVersion, binDir, kubeConfigPath string, opts ...Option) *Kind {
+	k := &Kind{
 		version:           kindVersion,
 		name:              "kind",
 		binDir:            binDir,
-		url:               fmt.Sprintf("https://github.com/kubernetes-sigs/kind/releases/download/v%s/kind-%s-%s", kindVersion, runtime.GOOS, runtime.GOARCH),
 		kubeConfigPath:    kubeConfigPath,
 		kubernetesVersion: kubernetesVersion,
+		platform:          cli.HostPlatform(),
 	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	k.url = fmt.Sprintf("https://github.com/kubernetes-sigs/kind/releases/download/v%s/kind-%s-%s", kindVersion, k.platform.OS, k.platform.Arch)
+	return k
 }
 
 func (k *Kind) Version() string {
@@ -50,16 +122,71 @@ func (k *Kind) URL() string {
 	return k.url
 }
 
+func (k *Kind) Logging() *cli.Logging {
+	return k.logging
+}
+
+func (k *Kind) Timeouts() *cli.Timeouts {
+	return k.timeouts
+}
+
+func (k *Kind) Executor() cli.Executor {
+	return k.executor
+}
+
+func (k *Kind) DownloadConfig() *cli.DownloadConfig {
+	return k.download
+}
+
+func (k *Kind) SHA256() string {
+	return k.sha256
+}
+
+func (k *Kind) SHA256URL() string {
+	return k.url + ".sha256sum"
+}
+
+func (k *Kind) VersionArgs() []string {
+	return []string{"version"}
+}
+
+// ParseVersion parses the output of kind version, e.g. "kind v0.11.0 go1.16.4 linux/amd64".
+func (k *Kind) ParseVersion(stdout string) (string, error) {
+	fields := strings.Fields(stdout)
+	if len(fields) < 2 || fields[0] != "kind" {
+		return "", fmt.Errorf("unexpected output of kind version: %q", stdout)
+	}
+	return strings.TrimPrefix(fields[1], "v"), nil
+}
+
+func (k *Kind) Platform() cli.Platform {
+	return k.platform
+}
+
+// NodeImage returns the image of the nodes of the cluster, e.g. "kindest/node:v1.20.2".
+func (k *Kind) NodeImage() string {
+	if k.nodeImage != "" {
+		return k.nodeImage
+	}
+	return "kindest/node:v" + k.kubernetesVersion
+}
+
 func (k *Kind) Envs() []string {
 	return []string{}
 }
 
-// Execute If OutPut is necessary, use Capture. Execute uses os.Stderr.
+// Execute If OutPut is necessary, use Capture.
+// Execute reports the output as configured by WithLogging, or uses os.Stdout and os.Stderr.
 func (k *Kind) Execute(ctx context.Context, args []string) error {
-	return cli.Run(ctx, k, args, os.Stdout, os.Stderr)
+	return cli.Execute(ctx, k, args)
 }
 
 // Capture execute command with returning outs as string.
 func (k *Kind) Capture(ctx context.Context, args []string) (stdout string, stderr string, err error) {
 	return cli.Capture(ctx, k, args)
 }
+
+// Stream execute command with calling fn for every line of the outputs as it arrives.
+func (k *Kind) Stream(ctx context.Context, args []string, fn func(line cli.Line)) error {
+	return cli.Stream(ctx, k, args, fn)
+}
@@ -2,10 +2,13 @@ package kubectl
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/riita10069/ket/pkg/cli"
 	"github.com/riita10069/ket/pkg/util/slice"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -217,44 +220,108 @@ func (k *Kubectl) DeleteResource(ctx context.Context, name, namespace, resource
 	return nil
 }
 
+var (
+	resourcePollInterval = 1 * time.Second
+	resourceWaitTimeout  = 5 * time.Minute
+)
+
+// ResourceNotReadyError is returned by WaitAResource when the resource doesn't become ready in time.
+type ResourceNotReadyError struct {
+	Resource       string
+	NamespacedName types.NamespacedName
+	// LastErr is the *cli.ExitError of the last kubectl get, e.g. because the resource type isn't registered yet.
+	// It is nil if the resource was found but not ready.
+	LastErr error
+	Err     error
+}
+
+func (e *ResourceNotReadyError) Error() string {
+	if e.LastErr != nil {
+		return fmt.Sprintf("%s/%s in %s is not ready: %v: %v", e.Resource, e.NamespacedName.Name, e.NamespacedName.Namespace, e.Err, e.LastErr)
+	}
+	return fmt.Sprintf("%s/%s in %s is not ready: %v", e.Resource, e.NamespacedName.Name, e.NamespacedName.Namespace, e.Err)
+}
+
+func (e *ResourceNotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// As finds target in LastErr too, so that errors.As returns the *cli.ExitError of the last kubectl get.
+func (e *ResourceNotReadyError) As(target interface{}) bool {
+	return e.LastErr != nil && errors.As(e.LastErr, target)
+}
+
 // WaitAResource waits until deploy is ready.
+// It gives up after resourceWaitTimeout or when ctx is done, and then the error is *ResourceNotReadyError.
+// The wait is recorded in the transcript as cli.EntryWait.
 func (k *Kubectl) WaitAResource(ctx context.Context, resource string, namespacedName types.NamespacedName) (ready bool, err error) {
 	resource = strings.ToLower(resource)
-	started := time.Now()
-	checkInterval := 1 * time.Second
-	timeout := 5 * time.Minute
+	name := fmt.Sprintf("%s/%s in %s", resource, namespacedName.Name, namespacedName.Namespace)
+	err = cli.Wait(ctx, k, name, func(ctx context.Context) error {
+		ready, err = k.waitAResource(ctx, resource, namespacedName)
+		return err
+	})
+	return ready, err
+}
 
+func (k *Kubectl) waitAResource(ctx context.Context, resource string, namespacedName types.NamespacedName) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, resourceWaitTimeout)
+	defer cancel()
+
+	var lastErr error
 	for {
-		if time.Since(started) > timeout {
-			return false, fmt.Errorf("waiting %s but it's time out", namespacedName.Name)
-		}
 		// First, Check if the resource exists.
 		resourceNameList, err := k.GetResourceNameList(ctx, namespacedName.Namespace, resource)
-		if err != nil {
-			return false, fmt.Errorf("failed to get %s/%s in %s: %w", resource, namespacedName.Name, namespacedName.Namespace, err)
-		}
-		if slice.Contains(resourceNameList, namespacedName.Name) {
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			// The resource type may not be registered yet, or the API server may be restarting.
+			if !IsNotFound(err) && !IsUnreachable(err) {
+				return false, fmt.Errorf("failed to get %s/%s in %s: %w", resource, namespacedName.Name, namespacedName.Namespace, err)
+			}
+			lastErr = err
+		case slice.Contains(resourceNameList, namespacedName.Name):
+			lastErr = nil
 			// Second, Check whether the STATUS of the resource is READY or not.
 			// However, check only for Pods, ReplicaSets, and Deployments.
-			if slice.Contains([]string{"po", "pod", "pods", "deploy", "deployment", "deployments"}, resource) {
-				ready, err := k.GetResourceStatusList(
-					ctx,
-					types.NamespacedName{
-						Namespace: namespacedName.Namespace,
-						Name:      namespacedName.Name,
-					}, resource)
-				if ready {
-					return true, nil
-				}
-				if err != nil {
-					return false, fmt.Errorf("failed to get current status: %w", err)
-				}
+			if !slice.Contains([]string{"po", "pod", "pods", "deploy", "deployment", "deployments"}, resource) {
+				return true, nil
+			}
+			ready, err := k.GetResourceStatusList(ctx, namespacedName, resource)
+			if ready {
+				return true, nil
+			}
+			if err != nil && ctx.Err() == nil {
+				return false, fmt.Errorf("failed to get current status: %w", err)
 			}
 		}
-		time.Sleep(checkInterval)
+
+		select {
+		case <-ctx.Done():
+			return false, &ResourceNotReadyError{Resource: resource, NamespacedName: namespacedName, LastErr: lastErr, Err: ctx.Err()}
+		case <-time.After(resourcePollInterval):
+		}
 	}
 }
 
+// PortForward exec kubectl port-forward in the background, e.g. PortForward(ctx, "default", "svc/ket", "8080:80").
+// The returned process is stopped gracefully when ctx is done or by Process.Stop.
+func (k *Kubectl) PortForward(ctx context.Context, namespace, resource string, ports ...string) (*cli.Process, error) {
+	args := []string{
+		"port-forward",
+		"-n",
+		namespace,
+		resource,
+	}
+	args = append(args, ports...)
+
+	process, err := k.Start(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute kubectl port-forward %s -n %s: %w", resource, namespace, err)
+	}
+	return process, nil
+}
+
 func formatOutput(s string) string {
 	if len(s) >= 2 {
 		if s[0] == '"' && s[len(s)-1] == '"' {
@@ -269,3 +336,44 @@ func formatOutput(s string) string {
 	}
 	return s
 }
+
+// ServerVersion returns the version of the API server, e.g. "1.20.2".
+func (k *Kubectl) ServerVersion(ctx context.Context) (string, error) {
+	args := []string{
+		"version",
+		"-o",
+		"json",
+	}
+
+	stdout, _, err := k.Capture(ctx, args)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute kubectl version: %w", err)
+	}
+	var v struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &v); err != nil {
+		return "", fmt.Errorf("failed to parse output of kubectl version: %w", err)
+	}
+	if v.ServerVersion.GitVersion == "" {
+		return "", fmt.Errorf("unexpected output of kubectl version: %q", stdout)
+	}
+	return strings.TrimPrefix(v.ServerVersion.GitVersion, "v"), nil
+}
+
+// Readyz checks the readiness of the API server.
+func (k *Kubectl) Readyz(ctx context.Context) error {
+	args := []string{
+		"get",
+		"--raw",
+		"/readyz",
+	}
+
+	_, _, err := k.Capture(ctx, args)
+	if err != nil {
+		return fmt.Errorf("api server is not ready: %w", err)
+	}
+	return nil
+}
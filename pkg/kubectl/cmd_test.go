@@ -0,0 +1,62 @@
+package kubectl_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/kubectl"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	namesJSONPath      = `-o=jsonpath='{.items[*].metadata.name}'`
+	conditionsJSONPath = `-o=jsonpath='-o=jsonpath='{.status.conditions[*].type}'`
+)
+
+func TestWaitAResource(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kubectl", "get", "deployment", "-n", "ket", namesJSONPath).
+		Return("", `error: the server doesn't have a resource type "deployment"`, 1)
+	fake.Expect("kubectl", "get", "deployment", "-n", "ket", namesJSONPath).Return("'other ket'", "", 0)
+	fake.Expect("kubectl", "get", "deployment", "ket", "-n", "ket", conditionsJSONPath).Return("'Available Progressing'", "", 0)
+	transcript := cli.NewTranscript()
+	kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(fake), kubectl.WithLogging(&cli.Logging{Transcript: transcript}))
+
+	ready, err := kc.WaitAResource(context.Background(), "Deployment", types.NamespacedName{Namespace: "ket", Name: "ket"})
+	if err != nil || !ready {
+		t.Errorf("WaitAResource() = %v, %v, want true", ready, err)
+	}
+
+	entries := transcript.Entries()
+	wait := entries[len(entries)-1]
+	if wait.Kind != cli.EntryWait || wait.Name != "deployment/ket in ket" || wait.Duration < time.Second {
+		t.Errorf("the last entry = %+v, want the wait for deployment/ket", wait)
+	}
+}
+
+func TestWaitAResourceTimeout(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kubectl", "get", "deployment", "-n", "ket", namesJSONPath).
+		Return("", `error: the server doesn't have a resource type "deployment"`, 1)
+	kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(fake))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ready, err := kc.WaitAResource(ctx, "deployment", types.NamespacedName{Namespace: "ket", Name: "ket"})
+	if ready {
+		t.Fatal("WaitAResource() = true, want false")
+	}
+
+	var notReady *kubectl.ResourceNotReadyError
+	if !errors.As(err, &notReady) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitAResource() error = %v, want *kubectl.ResourceNotReadyError", err)
+	}
+	var exitErr *cli.ExitError
+	if !errors.As(err, &exitErr) || !kubectl.IsNotFound(err) {
+		t.Errorf("WaitAResource() error = %v, want the last *cli.ExitError", err)
+	}
+}
@@ -0,0 +1,196 @@
+package kubectl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// crdName is the prefix of the CRDs in the output of kubectl apply -o name.
+const crdName = "customresourcedefinition.apiextensions.k8s.io/"
+
+var crdPollInterval = 500 * time.Millisecond
+
+// CRDNotReadyError is returned by WaitCRDs when the CRDs don't become ready before ctx is done.
+type CRDNotReadyError struct {
+	// NotReady are the reasons keyed by the names of the CRDs which are not ready.
+	NotReady map[string]string
+	Err      error
+}
+
+func (e *CRDNotReadyError) Error() string {
+	names := make([]string, 0, len(e.NotReady))
+	for name := range e.NotReady {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reasons := make([]string, 0, len(names))
+	for _, name := range names {
+		reasons = append(reasons, fmt.Sprintf("%s (%s)", name, e.NotReady[name]))
+	}
+	return fmt.Sprintf("crds are not ready: %s: %v", strings.Join(reasons, ", "), e.Err)
+}
+
+func (e *CRDNotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyKustomizeCRDs exec kubectl apply -k and returns the names of the CRDs in the kustomization, e.g. "foos.example.com".
+func (k *Kubectl) ApplyKustomizeCRDs(ctx context.Context, kustomizePath string) ([]string, error) {
+	if kustomizePath == "" {
+		return nil, nil
+	}
+	args := []string{
+		"apply",
+		"-k",
+		kustomizePath,
+		"-o",
+		"name",
+	}
+
+	stdout, _, err := k.Capture(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute kubectl apply -k %s: %w", kustomizePath, err)
+	}
+	var crds []string
+	for _, name := range strings.Fields(stdout) {
+		if strings.HasPrefix(name, crdName) {
+			crds = append(crds, strings.TrimPrefix(name, crdName))
+		}
+	}
+	return crds, nil
+}
+
+// WaitCRDs waits until the CRDs are Established and NamesAccepted,
+// and the API discovery serves their resources in every served version.
+// Bound it by ctx, e.g. with context.WithTimeout. Then the error is *CRDNotReadyError.
+// The wait is recorded in the transcript as cli.EntryWait.
+func (k *Kubectl) WaitCRDs(ctx context.Context, names []string) error {
+	return cli.Wait(ctx, k, "crds "+strings.Join(names, ", "), func(ctx context.Context) error {
+		return k.waitCRDs(ctx, names)
+	})
+}
+
+func (k *Kubectl) waitCRDs(ctx context.Context, names []string) error {
+	notReady := map[string]string{}
+	for _, name := range names {
+		notReady[name] = "not checked"
+	}
+
+	for {
+		for name := range notReady {
+			reason, err := k.crdNotReady(ctx, name)
+			if ctx.Err() != nil {
+				return &CRDNotReadyError{NotReady: notReady, Err: ctx.Err()}
+			}
+			if err != nil {
+				return err
+			}
+			if reason == "" {
+				delete(notReady, name)
+			} else {
+				notReady[name] = reason
+			}
+		}
+		if len(notReady) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return &CRDNotReadyError{NotReady: notReady, Err: ctx.Err()}
+		case <-time.After(crdPollInterval):
+		}
+	}
+}
+
+// crdNotReady returns why the CRD is not ready, or an empty string if it is ready.
+func (k *Kubectl) crdNotReady(ctx context.Context, name string) (string, error) {
+	stdout, _, err := k.Capture(ctx, []string{"get", "customresourcedefinition", name, "-o", "json"})
+	if err != nil {
+		if IsNotFound(err) || IsUnreachable(err) {
+			return "not found", nil
+		}
+		return "", fmt.Errorf("failed to get crd %s: %w", name, err)
+	}
+
+	var crd struct {
+		Spec struct {
+			Group string `json:"group"`
+			Names struct {
+				Plural string `json:"plural"`
+			} `json:"names"`
+			Versions []struct {
+				Name   string `json:"name"`
+				Served bool   `json:"served"`
+			} `json:"versions"`
+		} `json:"spec"`
+		Status struct {
+			Conditions []struct {
+				Type    string `json:"type"`
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &crd); err != nil {
+		return "", fmt.Errorf("failed to parse crd %s: %w", name, err)
+	}
+
+	for _, conditionType := range []string{"Established", "NamesAccepted"} {
+		status, message := "Unknown", ""
+		for _, c := range crd.Status.Conditions {
+			if c.Type == conditionType {
+				status, message = c.Status, c.Message
+			}
+		}
+		if status != "True" {
+			if message != "" {
+				return fmt.Sprintf("%s is %s: %s", conditionType, status, message), nil
+			}
+			return fmt.Sprintf("%s is %s", conditionType, status), nil
+		}
+	}
+
+	for _, version := range crd.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+		groupVersion := crd.Spec.Group + "/" + version.Name
+		served, err := k.discoveryServes(ctx, groupVersion, crd.Spec.Names.Plural)
+		if err != nil {
+			return "", err
+		}
+		if !served {
+			return groupVersion + " is not served by discovery", nil
+		}
+	}
+	return "", nil
+}
+
+// discoveryServes reports whether the API discovery of the group version serves the resource.
+func (k *Kubectl) discoveryServes(ctx context.Context, groupVersion, resource string) (bool, error) {
+	stdout, _, err := k.Capture(ctx, []string{"get", "--raw", "/apis/" + groupVersion})
+	if err != nil {
+		if IsNotFound(err) || IsUnreachable(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to discover %s: %w", groupVersion, err)
+	}
+	var resources metav1.APIResourceList
+	if err := json.Unmarshal([]byte(stdout), &resources); err != nil {
+		return false, fmt.Errorf("failed to parse discovery of %s: %w", groupVersion, err)
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
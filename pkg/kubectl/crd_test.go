@@ -0,0 +1,65 @@
+package kubectl_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/kubectl"
+)
+
+const notEstablished = `{
+  "spec": {"group": "example.com", "names": {"plural": "foos"}, "versions": [{"name": "v1", "served": true}]},
+  "status": {"conditions": [{"type": "NamesAccepted", "status": "True"}, {"type": "Established", "status": "False", "message": "not all names are accepted"}]}
+}`
+
+const established = `{
+  "spec": {"group": "example.com", "names": {"plural": "foos"}, "versions": [{"name": "v1", "served": true}]},
+  "status": {"conditions": [{"type": "NamesAccepted", "status": "True"}, {"type": "Established", "status": "True"}]}
+}`
+
+func TestWaitCRDs(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kubectl", "apply", "-k", "./config/crd", "-o", "name").
+		Return("customresourcedefinition.apiextensions.k8s.io/foos.example.com\nnamespace/ket\n", "", 0)
+	fake.Expect("kubectl", "get", "customresourcedefinition", "foos.example.com", "-o", "json").Return(notEstablished, "", 0)
+	fake.Expect("kubectl", "get", "customresourcedefinition", "foos.example.com", "-o", "json").Return(established, "", 0)
+	fake.Expect("kubectl", "get", "--raw", "/apis/example.com/v1").
+		Return("", "Error from server (NotFound): the server could not find the requested resource", 1)
+	fake.Expect("kubectl", "get", "customresourcedefinition", "foos.example.com", "-o", "json").Return(established, "", 0)
+	fake.Expect("kubectl", "get", "--raw", "/apis/example.com/v1").
+		Return(`{"kind":"APIResourceList","groupVersion":"example.com/v1","resources":[{"name":"foos","kind":"Foo","namespaced":true}]}`, "", 0)
+	kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(fake))
+
+	crds, err := kc.ApplyKustomizeCRDs(context.Background(), "./config/crd")
+	if err != nil {
+		t.Fatalf("ApplyKustomizeCRDs() error = %v", err)
+	}
+	if len(crds) != 1 || crds[0] != "foos.example.com" {
+		t.Fatalf("ApplyKustomizeCRDs() = %v, want [foos.example.com]", crds)
+	}
+	if err := kc.WaitCRDs(context.Background(), crds); err != nil {
+		t.Errorf("WaitCRDs() error = %v", err)
+	}
+}
+
+func TestWaitCRDsTimeout(t *testing.T) {
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kubectl", "get", "customresourcedefinition", "foos.example.com", "-o", "json").Return(notEstablished, "", 0)
+	kc := kubectl.NewKubectl("1.20.2", t.TempDir(), "./kubeconfig", kubectl.WithExecutor(fake))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := kc.WaitCRDs(ctx, []string{"foos.example.com"})
+
+	var notReady *kubectl.CRDNotReadyError
+	if !errors.As(err, &notReady) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitCRDs() error = %v, want *kubectl.CRDNotReadyError", err)
+	}
+	if !strings.Contains(err.Error(), "foos.example.com (Established is False: not all names are accepted)") {
+		t.Errorf("WaitCRDs() error = %v, want the reason", err)
+	}
+}
@@ -0,0 +1,31 @@
+package kubectl
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+// IsNotFound reports whether kubectl failed because the resource or the resource type doesn't exist (yet).
+func IsNotFound(err error) bool {
+	return stderrContains(err, "NotFound", "not found", "doesn't have a resource type")
+}
+
+// IsUnreachable reports whether kubectl failed because it couldn't connect to the API server.
+func IsUnreachable(err error) bool {
+	return stderrContains(err, "connection refused", "Unable to connect to the server", "i/o timeout")
+}
+
+func stderrContains(err error, substrs ...string) bool {
+	var exitErr *cli.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	for _, s := range substrs {
+		if strings.Contains(exitErr.Stderr, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,10 +2,11 @@ package kubectl
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
-	"runtime"
+	"strings"
 
 	"github.com/riita10069/ket/pkg/cli"
 )
@@ -16,16 +17,71 @@ type Kubectl struct {
 	binDir         string
 	url            string
 	kubeConfigPath string
+	logging        *cli.Logging
+	timeouts       *cli.Timeouts
+	executor       cli.Executor
+	download       *cli.DownloadConfig
+	platform       cli.Platform
+	sha256         string
 }
 
-func NewKubectl(version, binDir, kubeConfigFilePath string) *Kubectl {
-	return &Kubectl{
+type Option func(*Kubectl)
+
+// WithSHA256 pins the expected SHA-256 of the binary instead of fetching the published checksum file.
+func WithSHA256(sum string) Option {
+	return func(k *Kubectl) {
+		k.sha256 = sum
+	}
+}
+
+// WithDownloadConfig configures how the binary is downloaded.
+func WithDownloadConfig(config *cli.DownloadConfig) Option {
+	return func(k *Kubectl) {
+		k.download = config
+	}
+}
+
+// WithPlatform downloads the binary for the platform instead of the host, e.g. to export a bundle.
+func WithPlatform(platform cli.Platform) Option {
+	return func(k *Kubectl) {
+		k.platform = platform
+	}
+}
+
+// WithExecutor replaces the Executor of the commands, e.g. with a fake in unit tests.
+func WithExecutor(executor cli.Executor) Option {
+	return func(k *Kubectl) {
+		k.executor = executor
+	}
+}
+
+// WithTimeouts bounds every invocation of the commands.
+func WithTimeouts(timeouts *cli.Timeouts) Option {
+	return func(k *Kubectl) {
+		k.timeouts = timeouts
+	}
+}
+
+// WithLogging configures where the invocations and the output of the commands are reported.
+func WithLogging(logging *cli.Logging) Option {
+	return func(k *Kubectl) {
+		k.logging = logging
+	}
+}
+
+func NewKubectl(version, binDir, kubeConfigFilePath string, opts ...Option) *Kubectl {
+	k := &Kubectl{
 		version:        version,
 		name:           "kubectl",
 		binDir:         binDir,
-		url:            fmt.Sprintf("https://storage.googleapis.com/kubernetes-release/release/v%s/bin/%s/%s/kubectl", version, runtime.GOOS, runtime.GOARCH),
 		kubeConfigPath: kubeConfigFilePath,
+		platform:       cli.HostPlatform(),
+	}
+	for _, opt := range opts {
+		opt(k)
 	}
+	k.url = fmt.Sprintf("https://storage.googleapis.com/kubernetes-release/release/v%s/bin/%s/%s/kubectl", version, k.platform.OS, k.platform.Arch)
+	return k
 }
 
 func (k *Kubectl) Version() string {
@@ -48,18 +104,82 @@ func (k *Kubectl) URL() string {
 	return k.url
 }
 
+func (k *Kubectl) Logging() *cli.Logging {
+	return k.logging
+}
+
+func (k *Kubectl) Timeouts() *cli.Timeouts {
+	return k.timeouts
+}
+
+func (k *Kubectl) Executor() cli.Executor {
+	return k.executor
+}
+
+func (k *Kubectl) DownloadConfig() *cli.DownloadConfig {
+	return k.download
+}
+
+func (k *Kubectl) SHA256() string {
+	return k.sha256
+}
+
+func (k *Kubectl) SHA256URL() string {
+	return k.url + ".sha256"
+}
+
+func (k *Kubectl) VersionArgs() []string {
+	return []string{"version", "--client", "-o", "json"}
+}
+
+// ParseVersion parses the output of kubectl version --client -o json.
+func (k *Kubectl) ParseVersion(stdout string) (string, error) {
+	var v struct {
+		ClientVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"clientVersion"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &v); err != nil {
+		return "", fmt.Errorf("failed to parse output of kubectl version: %w", err)
+	}
+	if v.ClientVersion.GitVersion == "" {
+		return "", fmt.Errorf("unexpected output of kubectl version: %q", stdout)
+	}
+	return strings.TrimPrefix(v.ClientVersion.GitVersion, "v"), nil
+}
+
+func (k *Kubectl) Platform() cli.Platform {
+	return k.platform
+}
+
 func (k *Kubectl) Envs() []string {
 	return []string{
 		"KUBECONFIG=" + k.kubeConfigPath,
 	}
 }
 
-// Execute If OutPut is necessary, use Capture. Execute uses os.Stderr.
+// Execute If OutPut is necessary, use Capture.
+// Execute reports the output as configured by WithLogging, or uses os.Stdout and os.Stderr.
 func (k *Kubectl) Execute(ctx context.Context, args []string) error {
-	return cli.Run(ctx, k, args, os.Stdout, os.Stderr)
+	return cli.Execute(ctx, k, args)
+}
+
+// ExecuteWithStdin is Execute feeding stdin to the command.
+func (k *Kubectl) ExecuteWithStdin(ctx context.Context, args []string, stdin io.Reader) error {
+	return cli.ExecuteWithStdin(ctx, k, args, stdin)
 }
 
 // Capture execute command with returning outs as string.
 func (k *Kubectl) Capture(ctx context.Context, args []string) (stdout string, stderr string, err error) {
 	return cli.Capture(ctx, k, args)
 }
+
+// Stream execute command with calling fn for every line of the outputs as it arrives.
+func (k *Kubectl) Stream(ctx context.Context, args []string, fn func(line cli.Line)) error {
+	return cli.Stream(ctx, k, args, fn)
+}
+
+// Start execute command in the background.
+func (k *Kubectl) Start(ctx context.Context, args []string, opts ...cli.ProcessOption) (*cli.Process, error) {
+	return cli.Start(ctx, k, args, opts...)
+}
@@ -0,0 +1,89 @@
+package kubectl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// ApplyManifest executes kubectl apply -f - with the YAML or JSON manifest generated in the test.
+func (k *Kubectl) ApplyManifest(ctx context.Context, manifest []byte) error {
+	args := []string{
+		"apply",
+		"-f",
+		"-",
+	}
+
+	err := k.ExecuteWithStdin(ctx, args, bytes.NewReader(manifest))
+	if err != nil {
+		return fmt.Errorf("failed to execute kubectl apply -f -: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteManifest executes kubectl delete -f - with the YAML or JSON manifest generated in the test.
+func (k *Kubectl) DeleteManifest(ctx context.Context, manifest []byte) error {
+	args := []string{
+		"delete",
+		"-f",
+		"-",
+	}
+
+	err := k.ExecuteWithStdin(ctx, args, bytes.NewReader(manifest))
+	if err != nil {
+		return fmt.Errorf("failed to execute kubectl delete -f -: %w", err)
+	}
+
+	return nil
+}
+
+// ApplyObjects applies the objects, e.g. &corev1.ConfigMap{...}, without writing them to disk.
+// apiVersion and kind may be omitted for the types registered in client-go.
+func (k *Kubectl) ApplyObjects(ctx context.Context, objs ...runtime.Object) error {
+	manifest, err := encodeObjects(objs)
+	if err != nil {
+		return err
+	}
+	return k.ApplyManifest(ctx, manifest)
+}
+
+// DeleteObjects deletes the objects, e.g. &corev1.ConfigMap{...}.
+// apiVersion and kind may be omitted for the types registered in client-go.
+func (k *Kubectl) DeleteObjects(ctx context.Context, objs ...runtime.Object) error {
+	manifest, err := encodeObjects(objs)
+	if err != nil {
+		return err
+	}
+	return k.DeleteManifest(ctx, manifest)
+}
+
+// encodeObjects encodes the objects to a JSON manifest of v1 List.
+func encodeObjects(objs []runtime.Object) ([]byte, error) {
+	items := make([]json.RawMessage, 0, len(objs))
+	for _, obj := range objs {
+		if obj.GetObjectKind().GroupVersionKind().Empty() {
+			gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find kind of %T, set apiVersion and kind: %w", obj, err)
+			}
+			obj = obj.DeepCopyObject()
+			obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %T: %w", obj, err)
+		}
+		items = append(items, b)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+}
@@ -0,0 +1,67 @@
+package kubectl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ControlPlaneArgs returns the flags of the control-plane component, e.g. "kube-apiserver", keyed by the nodes running it.
+// The flags are keyed without the leading dashes, and a flag without a value is "true".
+// They are read from the mirror pods of the static pod manifests in /etc/kubernetes/manifests,
+// which kubelet creates shortly after the API server serves, so the result is empty until then.
+func (k *Kubectl) ControlPlaneArgs(ctx context.Context, component string) (map[string]map[string]string, error) {
+	args := []string{
+		"get",
+		"pod",
+		"-n",
+		"kube-system",
+		"-l",
+		"component=" + component,
+		"-o",
+		"json",
+	}
+
+	stdout, _, err := k.Capture(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get static pods of %s: %w", component, err)
+	}
+	var pods struct {
+		Items []struct {
+			Spec struct {
+				NodeName   string `json:"nodeName"`
+				Containers []struct {
+					Name    string   `json:"name"`
+					Command []string `json:"command"`
+					Args    []string `json:"args"`
+				} `json:"containers"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &pods); err != nil {
+		return nil, fmt.Errorf("failed to parse static pods of %s: %w", component, err)
+	}
+
+	nodes := map[string]map[string]string{}
+	for _, pod := range pods.Items {
+		flags := map[string]string{}
+		for _, container := range pod.Spec.Containers {
+			if container.Name != component {
+				continue
+			}
+			for _, arg := range append(container.Command, container.Args...) {
+				if !strings.HasPrefix(arg, "--") {
+					continue
+				}
+				key, value := strings.TrimPrefix(arg, "--"), "true"
+				if i := strings.Index(key, "="); i >= 0 {
+					key, value = key[:i], key[i+1:]
+				}
+				flags[key] = value
+			}
+		}
+		nodes[pod.Spec.NodeName] = flags
+	}
+	return nodes, nil
+}
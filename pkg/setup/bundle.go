@@ -0,0 +1,472 @@
+package setup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+const (
+	bundleManifestName = "manifest.json"
+	bundleCacheDir     = "cache"
+	bundleNodeImage    = "images/node.tar"
+	// nodeImagesDir is the directory in the cache recording the IDs of the pinned node images imported from bundles.
+	nodeImagesDir = "images"
+)
+
+// bundleManifest is the first entry of a bundle describing its content.
+type bundleManifest struct {
+	Platform  cli.Platform   `json:"platform"`
+	Binaries  []bundleBinary `json:"binaries"`
+	NodeImage string         `json:"nodeImage"`
+	// PinnedNodeImage is the node image pinned by digest in the lockfile, which is saved as NodeImage.
+	PinnedNodeImage string `json:"pinnedNodeImage,omitempty"`
+	// NodeImageID is the ID of the saved node image.
+	NodeImageID string `json:"nodeImageID,omitempty"`
+}
+
+type bundleBinary struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Path is the slash separated path of the binary in the bundle, relative to the cache directory on import.
+	Path string `json:"path"`
+	// SHA256 is the digest of the binary, which is verified on import.
+	SHA256 string `json:"sha256"`
+	// ArtifactSHA256 is the digest of the file the binary is downloaded from, e.g. an archive, which is verified against the lockfile.
+	ArtifactSHA256 string `json:"artifactSHA256"`
+}
+
+// ExportBundle writes everything Start needs with the options into a gzipped tarball at bundlePath,
+// i.e. kind, kubectl, skaffold (if WithUseSkaffold is used) and the tools added by WithTool for the platform,
+// and the kindest/node image saved by docker.
+// If WithLockfile is used, the node image pinned by digest is saved, so that Start with the lockfile uses it offline.
+// The platform is the host if it is zero.
+// Run ImportBundle on the machine without network access before Start.
+func ExportBundle(ctx context.Context, bundlePath string, platform cli.Platform, options ...Option) (err error) {
+	ket := NewKET()
+	for _, option := range options {
+		if err := option(ket); err != nil {
+			return fmt.Errorf("failed to run options: %w", err)
+		}
+	}
+	if platform == (cli.Platform{}) {
+		platform = cli.HostPlatform()
+	}
+
+	clis, err := ket.clisFor(platform)
+	if err != nil {
+		return err
+	}
+
+	manifest := bundleManifest{
+		Platform:  platform,
+		NodeImage: "kindest/node:v" + ket.kubernetesVersion,
+	}
+	cached := make([]string, 0, len(clis))
+	for _, c := range clis {
+		p, err := cli.Download(ctx, c)
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", c.Name(), err)
+		}
+		sum, err := fileSHA256(p)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", p, err)
+		}
+		artifactSHA256, err := cli.ArtifactSHA256(p)
+		if err != nil {
+			return err
+		}
+		cached = append(cached, p)
+		manifest.Binaries = append(manifest.Binaries, bundleBinary{
+			Name:           c.Name(),
+			Version:        c.Version(),
+			Path:           path.Join(c.Name(), c.Version(), platform.OS+"-"+platform.Arch, c.Name()),
+			SHA256:         sum,
+			ArtifactSHA256: artifactSHA256,
+		})
+	}
+
+	image := manifest.NodeImage
+	if ket.lockfile != "" {
+		lock, err := ReadLockfile(ket.lockfile)
+		if err != nil {
+			return err
+		}
+		pinned, ok := lock.NodeImages[ket.kubernetesVersion]
+		if !ok {
+			return fmt.Errorf("node image for kubernetes %s is not locked in %s", ket.kubernetesVersion, ket.lockfile)
+		}
+		image, manifest.PinnedNodeImage = pinned, pinned
+	}
+	saved, id, err := saveNodeImage(ctx, image, manifest.NodeImage, platform)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(saved)
+	manifest.NodeImageID = id
+
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(bundlePath)
+		}
+	}()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: bundleManifestName, Mode: 0o644, Size: int64(len(b)), Typeflag: tar.TypeReg}); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if _, err := tw.Write(b); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	for i, binary := range manifest.Binaries {
+		if err := addFile(tw, path.Join(bundleCacheDir, binary.Path), cached[i], 0o755); err != nil {
+			return fmt.Errorf("failed to add %s to bundle: %w", binary.Name, err)
+		}
+	}
+	if err := addFile(tw, bundleNodeImage, saved, 0o644); err != nil {
+		return fmt.Errorf("failed to add %s to bundle: %w", manifest.NodeImage, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// ImportBundle unpacks the bundle written by ExportBundle into the cache (see cli.CacheDir)
+// and loads the kindest/node image into docker, so that Start runs without network access.
+// Every binary is verified by the SHA-256 recorded in the bundle and by its version command as if it were downloaded.
+// The tools added by WithTool in options are verified by their version command too.
+// docker load doesn't restore the digest of the image, so the ID of the image pinned in the lockfile is recorded in the cache
+// for Start to use the loaded image by tag.
+func ImportBundle(ctx context.Context, bundlePath string, options ...Option) error {
+	ket := NewKET()
+	for _, option := range options {
+		if err := option(ket); err != nil {
+			return fmt.Errorf("failed to run options: %w", err)
+		}
+	}
+
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	if hdr.Name != bundleManifestName {
+		return fmt.Errorf("%s is not a bundle: the first entry is %s", bundlePath, hdr.Name)
+	}
+	var manifest bundleManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return fmt.Errorf("failed to read manifest of bundle: %w", err)
+	}
+	if manifest.Platform != cli.HostPlatform() {
+		return fmt.Errorf("bundle is for %s, not for %s", manifest.Platform, cli.HostPlatform())
+	}
+	cacheDir, err := cli.CacheDir()
+	if err != nil {
+		return err
+	}
+	clis, err := ket.bundledCLIs(manifest)
+	if err != nil {
+		return err
+	}
+	binaries := map[string]bundleBinary{}
+	for _, binary := range manifest.Binaries {
+		if _, err := binary.cachePath(cacheDir, manifest.Platform); err != nil {
+			return fmt.Errorf("%s is not a valid bundle: %w", bundlePath, err)
+		}
+		if binary.SHA256 == "" {
+			return fmt.Errorf("%s is not a valid bundle: sha256 of %s is missing", bundlePath, binary.Name)
+		}
+		binaries[path.Join(bundleCacheDir, binary.Path)] = binary
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+
+		binary, isBinary := binaries[hdr.Name]
+		switch {
+		case isBinary:
+			c := clis[binary.Name+" "+binary.Version]
+			if err := cli.Install(ctx, c, tr, bundlePath+":"+hdr.Name, binary.SHA256, binary.ArtifactSHA256); err != nil {
+				return fmt.Errorf("failed to import %s: %w", hdr.Name, err)
+			}
+		case hdr.Name == bundleNodeImage:
+			if err := loadNodeImage(ctx, tr); err != nil {
+				return fmt.Errorf("failed to import %s: %w", manifest.NodeImage, err)
+			}
+		default:
+			return fmt.Errorf("unexpected entry %s in bundle", hdr.Name)
+		}
+	}
+
+	if manifest.PinnedNodeImage == "" {
+		return nil
+	}
+	id, err := imageID(ctx, manifest.NodeImage)
+	if err != nil {
+		return err
+	}
+	if id != manifest.NodeImageID {
+		return fmt.Errorf("loaded %s is %s, but %s is saved in bundle", manifest.NodeImage, id, manifest.NodeImageID)
+	}
+	marker := nodeImageMarker(cacheDir, manifest.PinnedNodeImage)
+	if err := os.MkdirAll(filepath.Dir(marker), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(marker, []byte(id), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("failed to record %s: %w", manifest.PinnedNodeImage, err)
+	}
+	return nil
+}
+
+// localNodeImage returns the tag of the pinned node image if the image is imported from a bundle,
+// because docker can't resolve the digest of a loaded image, and kind would pull the pinned image.
+// The tag is used only if docker has the image with the ID recorded on import.
+func localNodeImage(ctx context.Context, pinned string) string {
+	cacheDir, err := cli.CacheDir()
+	if err != nil {
+		return pinned
+	}
+	want, err := os.ReadFile(nodeImageMarker(cacheDir, pinned))
+	if err != nil {
+		return pinned
+	}
+	if _, err := imageID(ctx, pinned); err == nil {
+		return pinned
+	}
+	tag := strings.SplitN(pinned, "@", 2)[0]
+	if id, err := imageID(ctx, tag); err != nil || id != string(want) {
+		return pinned
+	}
+	return tag
+}
+
+// nodeImageMarker returns the file recording the ID of the pinned image, named by its digest.
+func nodeImageMarker(cacheDir, pinned string) string {
+	digest := pinned[strings.LastIndex(pinned, "@")+1:]
+	return filepath.Join(cacheDir, nodeImagesDir, strings.ReplaceAll(digest, ":", "-"))
+}
+
+// cachePath returns where the binary is imported in cacheDir, the same as cli.CachePath.
+// The path in the manifest must be exactly <name>/<version>/<os>-<arch>/<name>, so that a crafted bundle can't write outside cacheDir.
+func (b bundleBinary) cachePath(cacheDir string, platform cli.Platform) (string, error) {
+	for _, element := range []string{b.Name, b.Version} {
+		if element == "" || element == "." || element == ".." || strings.ContainsAny(element, `/\:`) {
+			return "", fmt.Errorf("invalid binary %q version %q", b.Name, b.Version)
+		}
+	}
+	dir := path.Join(b.Name, b.Version, platform.OS+"-"+platform.Arch)
+	if b.Path != path.Join(dir, b.Name) && !(platform.OS == "windows" && b.Path == path.Join(dir, b.Name+".exe")) {
+		return "", fmt.Errorf("invalid path %q of %s %s", b.Path, b.Name, b.Version)
+	}
+	dst := filepath.Join(cacheDir, filepath.FromSlash(b.Path))
+	if rel, err := filepath.Rel(cacheDir, dst); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q of %s leaves the cache directory", b.Path, b.Name)
+	}
+	return dst, nil
+}
+
+func addFile(tw *tar.Writer, name, src string, mode int64) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: mode, Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// bundledCLIs returns the CLIs of the binaries in the manifest keyed by their names and versions.
+// kind, kubectl and skaffold are of the versions in the manifest. The other binaries are the tools added by WithTool,
+// or the CLIs which only tell where they are cached if they aren't added, whose versions can't be verified.
+func (k *KET) bundledCLIs(manifest bundleManifest) (map[string]cli.CLI, error) {
+	for _, binary := range manifest.Binaries {
+		switch binary.Name {
+		case "kind":
+			k.kindVersion = binary.Version
+		case "kubectl":
+			k.kubernetesVersion = binary.Version
+		case "skaffold":
+			k.useSkaffold, k.skaffoldVersion = true, binary.Version
+		}
+	}
+	clis, err := k.clisFor(manifest.Platform)
+	if err != nil {
+		return nil, err
+	}
+	bundled := map[string]cli.CLI{}
+	for _, c := range clis {
+		bundled[c.Name()+" "+c.Version()] = c
+	}
+	for _, binary := range manifest.Binaries {
+		key := binary.Name + " " + binary.Version
+		if _, ok := bundled[key]; !ok {
+			bundled[key] = &cachedBinary{name: binary.Name, version: binary.Version, platform: manifest.Platform}
+		}
+	}
+	return bundled, nil
+}
+
+// cachedBinary is a binary in the cache which is neither downloaded nor executed.
+type cachedBinary struct {
+	name     string
+	version  string
+	platform cli.Platform
+}
+
+func (b *cachedBinary) Name() string           { return b.name }
+func (b *cachedBinary) Version() string        { return b.version }
+func (b *cachedBinary) Path() string           { return "" }
+func (b *cachedBinary) Dir() string            { return "" }
+func (b *cachedBinary) URL() string            { return "" }
+func (b *cachedBinary) Envs() []string         { return nil }
+func (b *cachedBinary) Platform() cli.Platform { return b.platform }
+
+func fileSHA256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func docker() (*cli.Binary, error) {
+	dockerPath, err := exec.LookPath("docker")
+	if err != nil {
+		return nil, fmt.Errorf("docker is required for the node image: %w", err)
+	}
+	return cli.NewBinary(dockerPath), nil
+}
+
+// saveNodeImage pulls the node image for the platform and saves it into a temporary file as tag.
+// It returns the file and the ID of the image.
+func saveNodeImage(ctx context.Context, image, tag string, platform cli.Platform) (string, string, error) {
+	d, err := docker()
+	if err != nil {
+		return "", "", err
+	}
+	// The nodes are always linux containers.
+	if err := cli.Run(ctx, d, []string{"pull", "--platform", "linux/" + platform.Arch, image}, nil, nil); err != nil {
+		return "", "", fmt.Errorf("failed to pull %s: %w", image, err)
+	}
+	if image != tag {
+		// The image saved by digest is loaded without any name.
+		if err := cli.Run(ctx, d, []string{"tag", image, tag}, nil, nil); err != nil {
+			return "", "", fmt.Errorf("failed to tag %s as %s: %w", image, tag, err)
+		}
+	}
+	id, err := imageID(ctx, tag)
+	if err != nil {
+		return "", "", err
+	}
+
+	f, err := os.CreateTemp("", "ket-node-image-*.tar")
+	if err != nil {
+		return "", "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", "", err
+	}
+	if err := cli.Run(ctx, d, []string{"save", "--output", f.Name(), tag}, nil, nil); err != nil {
+		_ = os.Remove(f.Name())
+		return "", "", fmt.Errorf("failed to save %s: %w", tag, err)
+	}
+	return f.Name(), id, nil
+}
+
+// imageID returns the ID of the image in docker, e.g. "sha256:...".
+func imageID(ctx context.Context, image string) (string, error) {
+	d, err := docker()
+	if err != nil {
+		return "", err
+	}
+	stdout, _, err := cli.Capture(ctx, d, []string{"image", "inspect", "--format", "{{.Id}}", image})
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect %s: %w", image, err)
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// imageDigest pulls the image and returns its digest, e.g. "sha256:...".
+func imageDigest(ctx context.Context, image string) (string, error) {
+	d, err := docker()
+	if err != nil {
+		return "", err
+	}
+	if err := cli.Run(ctx, d, []string{"pull", image}, nil, nil); err != nil {
+		return "", fmt.Errorf("failed to pull %s: %w", image, err)
+	}
+	stdout, _, err := cli.Capture(ctx, d, []string{"image", "inspect", "--format", "{{index .RepoDigests 0}}", image})
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect %s: %w", image, err)
+	}
+	i := strings.LastIndex(stdout, "@")
+	if i < 0 {
+		return "", fmt.Errorf("unexpected digest of %s: %q", image, stdout)
+	}
+	return strings.TrimSpace(stdout[i+1:]), nil
+}
+
+func loadNodeImage(ctx context.Context, r io.Reader) error {
+	d, err := docker()
+	if err != nil {
+		return err
+	}
+	return cli.RunWithStdin(ctx, d, []string{"load"}, r, nil, nil)
+}
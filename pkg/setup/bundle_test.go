@@ -0,0 +1,353 @@
+package setup_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+// releases are the fake binaries served by the mirror, which print the version as the real ones.
+var releases = map[string]string{
+	"kind":    "#!/bin/sh\necho kind v0.11.0 go1.16.4 linux/amd64\n",
+	"kubectl": "#!/bin/sh\necho '{\"clientVersion\":{\"gitVersion\":\"v1.20.2\"}}'\n",
+}
+
+// nodeDigest is the digest of kindest/node reported by the fake docker.
+const nodeDigest = "sha256:98cf5288864662e37115e362b23e4369c8c4a408f99cbc06e58ac30ddc721600"
+
+// nodeID is the ID of kindest/node reported by the fake docker.
+const nodeID = "sha256:6c8ebd3a6c36a7b5d8ff8a9b0f2c8b1c7ef3b9f0ad0fbd4ea3c1a1a0c8f6e2d1"
+
+func TestBundle(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	mirrorURL, loaded := fakeMirrorAndDocker(t)
+
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	setEnv(t, cli.CacheDirEnv, t.TempDir())
+	err := setup.ExportBundle(context.Background(), bundle, cli.Platform{}, setup.WithMirrorURL(mirrorURL), setup.WithKubernetesVersion("1.20.2"))
+	if err != nil {
+		t.Fatalf("ExportBundle() error = %v", err)
+	}
+
+	// The offline machine.
+	cacheDir := t.TempDir()
+	setEnv(t, cli.CacheDirEnv, cacheDir)
+	if err := setup.ImportBundle(context.Background(), bundle); err != nil {
+		t.Fatalf("ImportBundle() error = %v", err)
+	}
+
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	for name, path := range map[string]string{
+		"kind":    filepath.Join(cacheDir, "kind", "0.11.0", platform, "kind"),
+		"kubectl": filepath.Join(cacheDir, "kubectl", "1.20.2", platform, "kubectl"),
+	} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s is not imported: %v", name, err)
+			continue
+		}
+		if string(b) != releases[name] {
+			t.Errorf("imported %s is %q, want %q", name, b, releases[name])
+		}
+		// The version is verified as if the binary were downloaded.
+		if _, err := os.Stat(filepath.Join(filepath.Dir(path), ".version")); err != nil {
+			t.Errorf("version of imported %s is not recorded: %v", name, err)
+		}
+	}
+	b, err := os.ReadFile(loaded)
+	if err != nil {
+		t.Fatalf("node image is not loaded: %v", err)
+	}
+	if string(b) != "image kindest/node:v1.20.2\n" {
+		t.Errorf("loaded %q, want the saved kindest/node image", b)
+	}
+}
+
+func TestImportBundleForAnotherPlatform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	mirrorURL, _ := fakeMirrorAndDocker(t)
+	setEnv(t, cli.CacheDirEnv, t.TempDir())
+
+	// The binaries for another platform are cached without running their version command.
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	err := setup.ExportBundle(context.Background(), bundle, cli.Platform{OS: "plan9", Arch: "386"}, setup.WithMirrorURL(mirrorURL))
+	if err != nil {
+		t.Fatalf("ExportBundle() error = %v", err)
+	}
+
+	err = setup.ImportBundle(context.Background(), bundle)
+	if err == nil || !strings.Contains(err.Error(), "plan9/386") {
+		t.Errorf("ImportBundle() error = %v, want the platform mismatch", err)
+	}
+}
+
+func TestBundleWithLockfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	mirrorURL, loaded := fakeMirrorAndDocker(t)
+	setEnv(t, cli.CacheDirEnv, t.TempDir())
+	lockfile := filepath.Join(t.TempDir(), setup.DefaultLockfile)
+	options := []setup.Option{setup.WithMirrorURL(mirrorURL), setup.WithKubernetesVersion("1.20.2"), setup.WithLockfile(lockfile)}
+	if err := setup.WriteLockfile(context.Background(), lockfile, nil, options...); err != nil {
+		t.Fatalf("WriteLockfile() error = %v", err)
+	}
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := setup.ExportBundle(context.Background(), bundle, cli.Platform{}, options...); err != nil {
+		t.Fatalf("ExportBundle() error = %v", err)
+	}
+
+	// The offline machine, where docker has never pulled the image.
+	if err := os.Remove(filepath.Join(filepath.Dir(loaded), "pulled")); err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, cli.CacheDirEnv, t.TempDir())
+	if err := setup.ImportBundle(context.Background(), bundle); err != nil {
+		t.Fatalf("ImportBundle() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", path)
+	// The loaded image is used by tag, since docker can't resolve its digest.
+	fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", "kindest/node:v1.20.2", "--kubeconfig", path)
+	fake.Expect("kubectl", "config", "use-context", "kind-ket")
+	_, err := setup.Start(context.Background(), append(options, setup.WithExecutor(fake), setup.WithKubeconfigPath(path))...)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+}
+
+func TestImportBundleMaliciousPath(t *testing.T) {
+	platform := cli.HostPlatform()
+	tests := []struct {
+		name   string
+		binary map[string]string
+	}{
+		{name: "path traversal", binary: map[string]string{"name": "kind", "version": "0.11.0", "path": "../../evil"}},
+		{name: "traversal in name", binary: map[string]string{"name": "..", "version": "..", "path": "../../" + platform.OS + "-" + platform.Arch + "/.."}},
+		{name: "another binary name", binary: map[string]string{"name": "kind", "version": "0.11.0", "path": "kind/0.11.0/" + platform.OS + "-" + platform.Arch + "/kubectl"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			cacheDir := filepath.Join(root, "a", "cache")
+			setEnv(t, cli.CacheDirEnv, cacheDir)
+
+			manifest, err := json.Marshal(map[string]interface{}{
+				"platform": platform,
+				"binaries": []map[string]string{tt.binary},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			bundle := filepath.Join(root, "bundle.tar.gz")
+			writeTarGz(t, bundle, map[string]string{
+				"manifest.json":              string(manifest),
+				"cache/" + tt.binary["path"]: "#!/bin/sh\necho pwned\n",
+			})
+
+			if err := setup.ImportBundle(context.Background(), bundle); err == nil {
+				t.Fatal("ImportBundle() error = nil, want the invalid path")
+			}
+			if _, err := os.Stat(filepath.Join(root, "evil")); !os.IsNotExist(err) {
+				t.Errorf("a file is written outside the cache directory: %v", err)
+			}
+		})
+	}
+}
+
+func TestImportBundleUnverified(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	platform := cli.HostPlatform()
+	kind := releases["kind"]
+	sum := sha256.Sum256([]byte(kind))
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "tampered binary", content: kind + "echo pwned\n"},
+		{name: "truncated binary", content: kind[:10]},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cacheDir := t.TempDir()
+			setEnv(t, cli.CacheDirEnv, cacheDir)
+			binary := "kind/0.11.0/" + platform.OS + "-" + platform.Arch + "/kind"
+			manifest, err := json.Marshal(map[string]interface{}{
+				"platform": platform,
+				"binaries": []map[string]string{{"name": "kind", "version": "0.11.0", "path": binary, "sha256": hex.EncodeToString(sum[:])}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+			writeTarGz(t, bundle, map[string]string{
+				"manifest.json":   string(manifest),
+				"cache/" + binary: tt.content,
+			})
+
+			err = setup.ImportBundle(context.Background(), bundle)
+			var checksumErr *cli.ChecksumError
+			if !errors.As(err, &checksumErr) {
+				t.Fatalf("ImportBundle() error = %v, want *cli.ChecksumError", err)
+			}
+			if _, err := os.Stat(filepath.Join(cacheDir, filepath.FromSlash(binary))); !os.IsNotExist(err) {
+				t.Errorf("the unverified binary is imported: %v", err)
+			}
+		})
+	}
+
+	t.Run("wrong version", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		setEnv(t, cli.CacheDirEnv, cacheDir)
+		content := strings.Replace(kind, "v0.11.0", "v0.10.0", 1)
+		sum := sha256.Sum256([]byte(content))
+		binary := "kind/0.11.0/" + platform.OS + "-" + platform.Arch + "/kind"
+		manifest, err := json.Marshal(map[string]interface{}{
+			"platform": platform,
+			"binaries": []map[string]string{{"name": "kind", "version": "0.11.0", "path": binary, "sha256": hex.EncodeToString(sum[:])}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+		writeTarGz(t, bundle, map[string]string{
+			"manifest.json":   string(manifest),
+			"cache/" + binary: content,
+		})
+
+		err = setup.ImportBundle(context.Background(), bundle)
+		var versionErr *cli.VersionError
+		if !errors.As(err, &versionErr) {
+			t.Fatalf("ImportBundle() error = %v, want *cli.VersionError", err)
+		}
+		if _, err := os.Stat(filepath.Join(cacheDir, filepath.FromSlash(binary))); !os.IsNotExist(err) {
+			t.Errorf("the unverified binary is imported: %v", err)
+		}
+	})
+}
+
+// writeTarGz writes the entries into a gzipped tarball with manifest.json as the first entry, as a bundle.
+func writeTarGz(t *testing.T, name string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	names := []string{"manifest.json"}
+	for entry := range entries {
+		if entry != "manifest.json" {
+			names = append(names, entry)
+		}
+	}
+	for _, entry := range names {
+		content := entries[entry]
+		if err := tw.WriteHeader(&tar.Header{Name: entry, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeMirrorAndDocker serves the fake releases and puts a fake docker on PATH.
+// It returns the URL of the mirror and the file docker load writes the image to.
+func fakeMirrorAndDocker(t *testing.T) (string, string) {
+	t.Helper()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, release := range releases {
+			if !strings.Contains(r.URL.Path, "/"+name) {
+				continue
+			}
+			if strings.HasSuffix(r.URL.Path, ".sha256sum") || strings.HasSuffix(r.URL.Path, ".sha256") {
+				sum := sha256.Sum256([]byte(release))
+				_, _ = w.Write([]byte(hex.EncodeToString(sum[:])))
+				return
+			}
+			_, _ = w.Write([]byte(release))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(mirror.Close)
+
+	dockerDir := t.TempDir()
+	loaded := filepath.Join(dockerDir, "loaded.tar")
+	pulled := filepath.Join(dockerDir, "pulled")
+	// A digest is resolved only for a pulled image, and a loaded image has only its tag.
+	script := "#!/bin/sh\n" +
+		"case $1 in\n" +
+		"pull) touch " + pulled + " ;;\n" +
+		"tag) ;;\n" +
+		"save) echo \"image $4\" > $3 ;;\n" +
+		"load) cat > " + loaded + " ;;\n" +
+		"image)\n" +
+		"  case $4 in\n" +
+		"  '{{.Id}}')\n" +
+		"    case $5 in\n" +
+		"    *@*) [ -f " + pulled + " ] || exit 1 ;;\n" +
+		"    *) [ -f " + pulled + " ] || [ -f " + loaded + " ] || exit 1 ;;\n" +
+		"    esac\n" +
+		"    echo " + nodeID + " ;;\n" +
+		"  *) echo kindest/node@" + nodeDigest + " ;;\n" +
+		"  esac ;;\n" +
+		"*) exit 1 ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(filepath.Join(dockerDir, "docker"), []byte(script), 0o755); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	setEnv(t, "PATH", dockerDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return mirror.URL, loaded
+}
+
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, old)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// KeepClusterEnv keeps the cluster and everything deployed to it on Close for debugging, e.g. KET_KEEP_CLUSTER=1.
+const KeepClusterEnv = "KET_KEEP_CLUSTER"
+
+// WithKeepCluster keeps the cluster on Close, but deletes the CRDs and the resources deployed by skaffold.
+// The cluster reused by WithReuseCluster is always kept.
+func WithKeepCluster() Option {
+	return func(k *KET) error {
+		k.keepCluster = true
+		return nil
+	}
+}
+
+// WithSkaffoldDelete runs skaffold delete on Close when the cluster is kept.
+func WithSkaffoldDelete() Option {
+	return func(k *KET) error {
+		k.skaffoldDelete = true
+		return nil
+	}
+}
+
+// WithTempKubeconfig writes the kubeconfig of the cluster to a temporary file instead of ~/.kube/config.
+// Close removes it.
+func WithTempKubeconfig() Option {
+	return func(k *KET) error {
+		k.tempKubeconfig = true
+		return nil
+	}
+}
+
+// closeErrors aggregates the errors of the steps of Close.
+// errors.Is and errors.As match any of them.
+type closeErrors []error
+
+func (e closeErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e closeErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e closeErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close tears down what Start has set up in the reverse order.
+// It stops skaffold dev, and deletes the cluster unless it is kept by WithKeepCluster or WithReuseCluster.
+// When the cluster is kept, the resources deployed by skaffold (with WithSkaffoldDelete) and the CRDs are deleted instead.
+// If KET_KEEP_CLUSTER is true, everything in the cluster and the kubeconfig are kept for debugging.
+// Every step runs even if a previous one fails, and the errors are aggregated.
+func (c *ClientSet) Close(ctx context.Context) error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	ket := c.ket
+	keepAll, _ := strconv.ParseBool(os.Getenv(KeepClusterEnv))
+	// The cluster is kept with WithReuseCluster even if Start had to create it, so that the next run reuses it.
+	keepCluster := keepAll || ket.keepCluster || ket.reuseCluster
+
+	var errs closeErrors
+	if c.SkaffoldProcess != nil {
+		err := ket.phase(ctx, "stop skaffold", func(ctx context.Context) error {
+			return c.SkaffoldProcess.Stop()
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop skaffold: %w", err))
+		}
+	}
+
+	if keepCluster && !keepAll {
+		if ket.skaffoldDelete && c.Skaffold != nil {
+			err := ket.phase(ctx, "skaffold delete", func(ctx context.Context) error {
+				return c.Skaffold.Delete(ctx, ket.skaffoldYaml)
+			})
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if ket.isThereCRD && ket.crdKustomizePath != "" && c.Kubectl != nil {
+			err := ket.phase(ctx, "delete crd", func(ctx context.Context) error {
+				return c.Kubectl.DeleteKustomize(ctx, ket.crdKustomizePath)
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete crd: %w", err))
+			}
+		}
+	}
+
+	if !keepCluster && c.Kind != nil {
+		err := ket.phase(ctx, "delete cluster", func(ctx context.Context) error {
+			return c.Kind.DeleteCluster(ctx, ket.kindClusterName)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete kind cluster %s: %w", ket.kindClusterName, err))
+		}
+	}
+
+	errs = append(errs, c.release()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// release removes the temporary kubeconfig unless KET_KEEP_CLUSTER is true, and closes the log file.
+func (c *ClientSet) release() closeErrors {
+	var errs closeErrors
+	keepAll, _ := strconv.ParseBool(os.Getenv(KeepClusterEnv))
+	if c.tempDir != "" && !keepAll {
+		if err := os.RemoveAll(c.tempDir); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove kubeconfig: %w", err))
+		}
+	}
+	if c.logFile != nil {
+		if err := c.logFile.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close log file: %w", err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,127 @@
+package setup_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+func TestClose(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []setup.Option
+		keepEnv  string
+		reuse    bool
+		expect   func(fake *kettest.FakeExecutor, kubeconfig string)
+		wantExit int
+	}{
+		{
+			name: "delete cluster",
+			expect: func(fake *kettest.FakeExecutor, kubeconfig string) {
+				fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", kubeconfig)
+			},
+		},
+		{
+			name:    "keep cluster",
+			options: []setup.Option{setup.WithKeepCluster(), setup.WithCRDKustomizePath("./config/crd")},
+			expect: func(fake *kettest.FakeExecutor, kubeconfig string) {
+				fake.Expect("kubectl", "delete", "-k", "./config/crd")
+			},
+		},
+		{
+			name:    "keep cluster created by reuse",
+			options: []setup.Option{setup.WithReuseCluster()},
+			reuse:   true,
+			expect: func(fake *kettest.FakeExecutor, kubeconfig string) {
+				fake.Expect("kubectl", "delete", "-k", "./config/crd")
+			},
+		},
+		{
+			name:    "keep everything for debugging",
+			options: []setup.Option{setup.WithCRDKustomizePath("./config/crd")},
+			keepEnv: "1",
+			expect:  func(fake *kettest.FakeExecutor, kubeconfig string) {},
+		},
+		{
+			name: "aggregated error",
+			expect: func(fake *kettest.FakeExecutor, kubeconfig string) {
+				fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", kubeconfig).Return("", "ERROR: failed to delete cluster", 1)
+			},
+			wantExit: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, setup.KeepClusterEnv, tt.keepEnv)
+			path := filepath.Join(t.TempDir(), "kubeconfig")
+			if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			fake := kettest.NewFakeExecutor(t)
+			if tt.reuse {
+				// The first run finds no cluster to reuse.
+				fake.Expect("kind", "get", "clusters").Return("", "No kind clusters found.", 0)
+			}
+			fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", path)
+			fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", kettest.Any, "--kubeconfig", path)
+			fake.Expect("kubectl", "config", "use-context", "kind-ket")
+			fake.Expect("kubectl", "apply", "-k", kettest.Any, "-o", "name")
+			options := append([]setup.Option{setup.WithExecutor(fake), setup.WithKubeconfigPath(path), setup.WithCRDKustomizePath("./config/crd")}, tt.options...)
+			cliSet, err := setup.Start(context.Background(), options...)
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+
+			tt.expect(fake, path)
+			err = cliSet.Close(context.Background())
+			var exitErr *cli.ExitError
+			if tt.wantExit != 0 {
+				if !errors.As(err, &exitErr) || exitErr.ExitCode != tt.wantExit {
+					t.Errorf("Close() error = %v, want *cli.ExitError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if err := cliSet.Close(context.Background()); err != nil {
+				t.Errorf("second Close() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestStartFailureReleases(t *testing.T) {
+	tmpDir := t.TempDir()
+	setEnv(t, "TMPDIR", tmpDir)
+	setEnv(t, setup.KeepClusterEnv, "")
+
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", kettest.Any)
+	fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", kettest.Any, "--kubeconfig", kettest.Any).Return("", "ERROR: failed to create cluster", 1)
+	_, err := setup.Start(
+		context.Background(),
+		setup.WithExecutor(fake),
+		setup.WithTempKubeconfig(),
+		setup.WithLogFile(filepath.Join(t.TempDir(), "e2e.log")),
+	)
+	if err == nil {
+		t.Fatal("Start() error = nil, want the failure of kind create cluster")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("%s is left after Start failed", entry.Name())
+	}
+}
@@ -0,0 +1,147 @@
+package setup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/riita10069/ket/pkg/kind"
+	"github.com/riita10069/ket/pkg/kubectl"
+)
+
+// controlPlanePollInterval is the interval to check the static pods until kubelet mirrors them.
+var controlPlanePollInterval = 500 * time.Millisecond
+
+// controlPlaneTimeout bounds the wait for the static pods of the control plane.
+const controlPlaneTimeout = time.Minute
+
+// WithAPIServerExtraArgs starts kube-apiserver with the flags, e.g. {"enable-admission-plugins": "NodeRestriction,PodSecurityPolicy"}.
+// The keys are the flags without the leading dashes. Start fails if the API server doesn't run with them.
+func WithAPIServerExtraArgs(args map[string]string) Option {
+	return func(k *KET) error {
+		k.controlPlane.APIServer = mergeArgs(k.controlPlane.APIServer, args)
+		return nil
+	}
+}
+
+// WithControllerManagerExtraArgs starts kube-controller-manager with the flags, e.g. {"node-monitor-grace-period": "10s"}.
+func WithControllerManagerExtraArgs(args map[string]string) Option {
+	return func(k *KET) error {
+		k.controlPlane.ControllerManager = mergeArgs(k.controlPlane.ControllerManager, args)
+		return nil
+	}
+}
+
+// WithSchedulerExtraArgs starts kube-scheduler with the flags, e.g. {"v": "4"}.
+func WithSchedulerExtraArgs(args map[string]string) Option {
+	return func(k *KET) error {
+		k.controlPlane.Scheduler = mergeArgs(k.controlPlane.Scheduler, args)
+		return nil
+	}
+}
+
+// WithKubeadmConfigPatches adds the patches to the kubeadm config of every node, e.g. the ClusterConfiguration.
+// See https://kind.sigs.k8s.io/docs/user/configuration/#kubeadm-config-patches.
+func WithKubeadmConfigPatches(patches ...string) Option {
+	return func(k *KET) error {
+		k.kubeadmConfigPatches = append(k.kubeadmConfigPatches, patches...)
+		return nil
+	}
+}
+
+func mergeArgs(args, added map[string]string) map[string]string {
+	if args == nil {
+		args = map[string]string{}
+	}
+	for key, value := range added {
+		args[key] = value
+	}
+	return args
+}
+
+// clusterConfig returns the kind config given by WithKindConfig with the patches of the control plane.
+func (k *KET) clusterConfig() (*kind.Cluster, error) {
+	if len(k.kubeadmConfigPatches) == 0 && k.controlPlane.IsZero() {
+		return k.kindConfig, nil
+	}
+	var config kind.Cluster
+	if k.kindConfig != nil {
+		config = *k.kindConfig
+	}
+	config.KubeadmConfigPatches = append(append([]string{}, config.KubeadmConfigPatches...), k.kubeadmConfigPatches...)
+	if !k.controlPlane.IsZero() {
+		patch, err := k.controlPlane.Patch()
+		if err != nil {
+			return nil, err
+		}
+		config.KubeadmConfigPatches = append(config.KubeadmConfigPatches, patch)
+	}
+	return &config, nil
+}
+
+// verifyControlPlane checks that the control-plane components run with the extra args on every control-plane node,
+// because a patch which kubeadm doesn't recognize is silently ignored.
+func (k *KET) verifyControlPlane(ctx context.Context, kubectl *kubectl.Kubectl) error {
+	ctx, cancel := context.WithTimeout(ctx, controlPlaneTimeout)
+	defer cancel()
+	for _, component := range []struct {
+		name string
+		want map[string]string
+	}{
+		{name: "kube-apiserver", want: k.controlPlane.APIServer},
+		{name: "kube-controller-manager", want: k.controlPlane.ControllerManager},
+		{name: "kube-scheduler", want: k.controlPlane.Scheduler},
+	} {
+		if len(component.want) == 0 {
+			continue
+		}
+		nodes, err := waitControlPlaneArgs(ctx, kubectl, component.name)
+		if err != nil {
+			return err
+		}
+		for node, flags := range nodes {
+			if err := diffArgs(flags, component.want); err != nil {
+				return fmt.Errorf("%s on %s %w", component.name, node, err)
+			}
+		}
+	}
+	return nil
+}
+
+// waitControlPlaneArgs waits until kubelet mirrors the static pods of the component.
+func waitControlPlaneArgs(ctx context.Context, kubectl *kubectl.Kubectl, component string) (map[string]map[string]string, error) {
+	for {
+		nodes, err := kubectl.ControlPlaneArgs(ctx, component)
+		if err != nil {
+			return nil, err
+		}
+		if len(nodes) > 0 {
+			return nodes, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("static pod of %s is not found: %w", component, ctx.Err())
+		case <-time.After(controlPlanePollInterval):
+		}
+	}
+}
+
+// diffArgs reports the first flag, in the order of the keys, which doesn't have the wanted value.
+func diffArgs(flags, want map[string]string) error {
+	keys := make([]string, 0, len(want))
+	for key := range want {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		got, ok := flags[key]
+		if !ok {
+			return fmt.Errorf("is running without --%s, want %q", key, want[key])
+		}
+		if got != want[key] {
+			return fmt.Errorf("is running with --%s=%s, want %q", key, got, want[key])
+		}
+	}
+	return nil
+}
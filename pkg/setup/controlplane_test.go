@@ -0,0 +1,73 @@
+package setup_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+func apiServerPods(command ...string) string {
+	return `{"items": [{"spec": {"nodeName": "ket-control-plane", "containers": [{"name": "kube-apiserver", "command": ["` +
+		strings.Join(append([]string{"kube-apiserver"}, command...), `", "`) + `"]}]}}]}`
+}
+
+func TestStartWithAPIServerExtraArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		pods    []string
+		wantErr string
+	}{
+		{
+			name: "running with the args",
+			pods: []string{apiServerPods("--enable-admission-plugins=NodeRestriction,PodSecurityPolicy", "--max-requests-inflight=10")},
+		},
+		{
+			name: "static pod is mirrored later",
+			pods: []string{`{"items": []}`, apiServerPods("--enable-admission-plugins=NodeRestriction,PodSecurityPolicy", "--max-requests-inflight=10")},
+		},
+		{
+			name:    "patch is ignored",
+			pods:    []string{apiServerPods("--enable-admission-plugins=NodeRestriction")},
+			wantErr: `kube-apiserver on ket-control-plane is running with --enable-admission-plugins=NodeRestriction, want "NodeRestriction,PodSecurityPolicy"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "kubeconfig")
+			if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			fake := kettest.NewFakeExecutor(t)
+			fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", path)
+			fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", "kindest/node:v1.20.2", "--kubeconfig", path, "--config", kettest.Any)
+			for _, pods := range tt.pods {
+				fake.Expect("kubectl", "get", "pod", "-n", "kube-system", "-l", "component=kube-apiserver", "-o", "json").Return(pods, "", 0)
+			}
+			if tt.wantErr == "" {
+				fake.Expect("kubectl", "config", "use-context", "kind-ket")
+			}
+
+			_, err := setup.Start(
+				context.Background(),
+				setup.WithExecutor(fake),
+				setup.WithKubeconfigPath(path),
+				setup.WithAPIServerExtraArgs(map[string]string{"enable-admission-plugins": "NodeRestriction,PodSecurityPolicy"}),
+				setup.WithAPIServerExtraArgs(map[string]string{"max-requests-inflight": "10"}),
+				setup.WithKubeadmConfigPatches("kind: InitConfiguration\nnodeRegistration:\n  kubeletExtraArgs:\n    v: \"4\"\n"),
+			)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Start() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
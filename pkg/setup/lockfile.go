@@ -0,0 +1,261 @@
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kind"
+	"github.com/riita10069/ket/pkg/kubectl"
+	"github.com/riita10069/ket/pkg/skaffold"
+)
+
+// DefaultLockfile is the conventional name of the lockfile.
+const DefaultLockfile = "ket.lock"
+
+// Lockfile pins the tools and the node images so that every developer and CI job runs identical tooling.
+type Lockfile struct {
+	Tools []LockedTool `json:"tools"`
+	// NodeImages are the kindest/node images pinned by digest, keyed by the Kubernetes version,
+	// e.g. "1.20.2": "kindest/node:v1.20.2@sha256:...".
+	NodeImages map[string]string `json:"nodeImages"`
+}
+
+// LockedTool is a version of a tool with its artifacts for each platform.
+type LockedTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Platforms are keyed by e.g. "linux/amd64".
+	Platforms map[string]LockedArtifact `json:"platforms"`
+}
+
+// LockedArtifact is the file downloaded for a platform.
+type LockedArtifact struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// WithLockfile verifies the versions and URLs of kind, kubectl, skaffold and the tools against the lockfile
+// written by WriteLockfile, and pins their SHA-256 and the kindest/node image by digest.
+// Start fails if any of them isn't locked for the host.
+func WithLockfile(path string) Option {
+	return func(k *KET) error {
+		k.lockfile = path
+		return nil
+	}
+}
+
+// ReadLockfile reads the lockfile at path.
+func ReadLockfile(path string) (*Lockfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+	var lock Lockfile
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+	return &lock, nil
+}
+
+// WriteLockfile regenerates the lockfile at path for the options given to Start.
+// kind, kubectl, skaffold (if WithUseSkaffold is used) and the tools added by WithTool are locked for each platform,
+// which is the host if platforms is empty. The SHA-256 is the published one, or the one of the downloaded file.
+// The kindest/node image is pulled by docker to resolve its digest.
+func WriteLockfile(ctx context.Context, path string, platforms []cli.Platform, options ...Option) error {
+	ket := NewKET()
+	for _, option := range options {
+		if err := option(ket); err != nil {
+			return fmt.Errorf("failed to run options: %w", err)
+		}
+	}
+	if len(platforms) == 0 {
+		platforms = []cli.Platform{cli.HostPlatform()}
+	}
+
+	lock := &Lockfile{NodeImages: map[string]string{}}
+	for _, platform := range platforms {
+		clis, err := ket.clisFor(platform)
+		if err != nil {
+			return err
+		}
+		for _, c := range clis {
+			sum, err := cli.SHA256Of(ctx, c)
+			if err != nil {
+				return fmt.Errorf("failed to lock %s %s for %s: %w", c.Name(), c.Version(), platform, err)
+			}
+			lock.add(c, platform, LockedArtifact{URL: c.URL(), SHA256: sum})
+		}
+	}
+
+	image := "kindest/node:v" + ket.kubernetesVersion
+	digest, err := imageDigest(ctx, image)
+	if err != nil {
+		return err
+	}
+	lock.NodeImages[ket.kubernetesVersion] = image + "@" + digest
+
+	b, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	return nil
+}
+
+func (l *Lockfile) add(c cli.CLI, platform cli.Platform, artifact LockedArtifact) {
+	for i := range l.Tools {
+		if l.Tools[i].Name == c.Name() && l.Tools[i].Version == c.Version() {
+			l.Tools[i].Platforms[platform.String()] = artifact
+			return
+		}
+	}
+	l.Tools = append(l.Tools, LockedTool{
+		Name:      c.Name(),
+		Version:   c.Version(),
+		Platforms: map[string]LockedArtifact{platform.String(): artifact},
+	})
+}
+
+// artifact returns the locked artifact of the version of the tool for the host.
+func (l *Lockfile) artifact(name, version string) (LockedArtifact, error) {
+	host := cli.HostPlatform().String()
+	var locked []string
+	for _, tool := range l.Tools {
+		if tool.Name != name {
+			continue
+		}
+		if tool.Version != version {
+			locked = append(locked, tool.Version)
+			continue
+		}
+		artifact, ok := tool.Platforms[host]
+		if !ok {
+			platforms := make([]string, 0, len(tool.Platforms))
+			for p := range tool.Platforms {
+				platforms = append(platforms, p)
+			}
+			sort.Strings(platforms)
+			return LockedArtifact{}, fmt.Errorf("%s %s is not locked for %s: locked for %s", name, version, host, strings.Join(platforms, ", "))
+		}
+		return artifact, nil
+	}
+	if len(locked) > 0 {
+		return LockedArtifact{}, fmt.Errorf("%s %s is not locked: locked versions are %s", name, version, strings.Join(locked, ", "))
+	}
+	return LockedArtifact{}, fmt.Errorf("%s is not locked", name)
+}
+
+// pins returns the SHA-256 of the tools keyed by their name and the node image locked for the configuration of k.
+// It returns nothing if no lockfile is used.
+func (k *KET) pins() (map[string]LockedArtifact, string, error) {
+	if k.lockfile == "" {
+		return map[string]LockedArtifact{}, "", nil
+	}
+	switch policy := k.download.Policy; policy {
+	case "", cli.DownloadOnly:
+	default:
+		// The binary on PATH can't be verified against the file locked for the download.
+		return nil, "", fmt.Errorf("resolve policy %s can't be used with lockfile %s", policy, k.lockfile)
+	}
+	lock, err := ReadLockfile(k.lockfile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	versions := map[string]string{
+		"kind":    k.kindVersion,
+		"kubectl": k.kubernetesVersion,
+	}
+	if k.useSkaffold {
+		versions["skaffold"] = k.skaffoldVersion
+	}
+	for _, spec := range k.tools {
+		versions[spec.Name] = spec.Version
+	}
+	pins := map[string]LockedArtifact{}
+	for name, version := range versions {
+		artifact, err := lock.artifact(name, version)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w in %s", err, k.lockfile)
+		}
+		pins[name] = artifact
+	}
+
+	image, ok := lock.NodeImages[k.kubernetesVersion]
+	if !ok {
+		return nil, "", fmt.Errorf("node image for kubernetes %s is not locked in %s", k.kubernetesVersion, k.lockfile)
+	}
+	return pins, image, nil
+}
+
+// verifyPin checks that c is downloaded from the locked URL,
+// and that the cached binary, which may be downloaded before or imported from a bundle, comes from the locked file.
+func (k *KET) verifyPin(ctx context.Context, c cli.CLI, pins map[string]LockedArtifact) error {
+	artifact, ok := pins[c.Name()]
+	if !ok {
+		return nil
+	}
+	if artifact.URL != c.URL() {
+		return fmt.Errorf("url of %s %s is %s, but %s is locked", c.Name(), c.Version(), c.URL(), artifact.URL)
+	}
+	if !k.installsBinaries() {
+		return nil
+	}
+	cached, err := cli.Download(ctx, c)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", c.Name(), err)
+	}
+	sum, err := cli.ArtifactSHA256(cached)
+	if err != nil {
+		return err
+	}
+	if sum != artifact.SHA256 {
+		return &cli.ChecksumError{Name: c.Name(), Version: c.Version(), URL: c.URL(), Expected: artifact.SHA256, Actual: sum}
+	}
+	return nil
+}
+
+// clisFor returns kind, kubectl, skaffold and the tools configured by the options for the platform.
+func (k *KET) clisFor(platform cli.Platform) ([]cli.CLI, error) {
+	clis := []cli.CLI{
+		kind.NewKind(
+			k.kindVersion,
+			k.kubernetesVersion,
+			k.binDir,
+			k.kubeconfigPath,
+			kind.WithDownloadConfig(&k.download),
+			kind.WithPlatform(platform),
+		),
+		kubectl.NewKubectl(
+			k.kubernetesVersion,
+			k.binDir,
+			k.kubeconfigPath,
+			kubectl.WithDownloadConfig(&k.download),
+			kubectl.WithPlatform(platform),
+		),
+	}
+	if k.useSkaffold {
+		clis = append(clis, skaffold.NewSkaffold(
+			k.skaffoldVersion,
+			k.binDir,
+			k.kubeconfigPath,
+			skaffold.WithDownloadConfig(&k.download),
+			skaffold.WithPlatform(platform),
+		))
+	}
+	for _, spec := range k.tools {
+		tool, err := cli.NewTool(spec, k.binDir, cli.WithToolDownloadConfig(&k.download), cli.WithToolPlatform(platform))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tool %s: %w", spec.Name, err)
+		}
+		clis = append(clis, tool)
+	}
+	return clis, nil
+}
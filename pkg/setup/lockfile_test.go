@@ -0,0 +1,118 @@
+package setup_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+func TestWriteLockfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	mirrorURL, _ := fakeMirrorAndDocker(t)
+	setEnv(t, cli.CacheDirEnv, t.TempDir())
+
+	path := filepath.Join(t.TempDir(), setup.DefaultLockfile)
+	platforms := []cli.Platform{cli.HostPlatform(), {OS: "plan9", Arch: "386"}}
+	if err := setup.WriteLockfile(context.Background(), path, platforms, setup.WithMirrorURL(mirrorURL), setup.WithKubernetesVersion("1.20.2")); err != nil {
+		t.Fatalf("WriteLockfile() error = %v", err)
+	}
+
+	lock, err := setup.ReadLockfile(path)
+	if err != nil {
+		t.Fatalf("ReadLockfile() error = %v", err)
+	}
+	if got, want := lock.NodeImages["1.20.2"], "kindest/node:v1.20.2@"+nodeDigest; got != want {
+		t.Errorf("NodeImages[1.20.2] = %q, want %q", got, want)
+	}
+	if len(lock.Tools) != 2 {
+		t.Fatalf("locked %d tools, want kind and kubectl: %+v", len(lock.Tools), lock.Tools)
+	}
+	for _, tool := range lock.Tools {
+		if len(tool.Platforms) != 2 {
+			t.Errorf("%s is locked for %d platforms, want 2", tool.Name, len(tool.Platforms))
+		}
+		artifact := tool.Platforms[cli.HostPlatform().String()]
+		sum := sha256.Sum256([]byte(releases[tool.Name]))
+		if artifact.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("sha256 of %s = %s, want the published one", tool.Name, artifact.SHA256)
+		}
+		if !strings.Contains(artifact.URL, runtime.GOOS) {
+			t.Errorf("url of %s = %s, want the one for the host", tool.Name, artifact.URL)
+		}
+	}
+}
+
+func TestStartWithLockfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	mirrorURL, _ := fakeMirrorAndDocker(t)
+	setEnv(t, cli.CacheDirEnv, t.TempDir())
+	path := filepath.Join(t.TempDir(), setup.DefaultLockfile)
+	if err := setup.WriteLockfile(context.Background(), path, nil, setup.WithMirrorURL(mirrorURL), setup.WithKubernetesVersion("1.20.2")); err != nil {
+		t.Fatalf("WriteLockfile() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options []setup.Option
+		wantErr string
+	}{
+		{name: "kind is not locked", options: []setup.Option{setup.WithKindVersion("0.10.0")}, wantErr: "kind 0.10.0 is not locked: locked versions are 0.11.0"},
+		{name: "kubernetes is not locked", options: []setup.Option{setup.WithKubernetesVersion("1.21.1")}, wantErr: "kubectl 1.21.1 is not locked"},
+		{name: "skaffold is not locked", options: []setup.Option{setup.WithKubernetesVersion("1.20.2"), setup.WithUseSkaffold()}, wantErr: "skaffold is not locked"},
+		{name: "binary on PATH", options: []setup.Option{setup.WithResolvePolicy(cli.PreferSystem)}, wantErr: "resolve policy prefer-system can't be used with lockfile"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Start fails before executing any command.
+			options := append([]setup.Option{setup.WithLockfile(path), setup.WithExecutor(kettest.NewFakeExecutor(t))}, tt.options...)
+			_, err := setup.Start(context.Background(), options...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Start() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStartWithLockfileTamperedCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	mirrorURL, _ := fakeMirrorAndDocker(t)
+	cacheDir := t.TempDir()
+	setEnv(t, cli.CacheDirEnv, cacheDir)
+	path := filepath.Join(t.TempDir(), setup.DefaultLockfile)
+	options := []setup.Option{setup.WithMirrorURL(mirrorURL), setup.WithKubernetesVersion("1.20.2"), setup.WithBinaryDirectory(t.TempDir())}
+	if err := setup.WriteLockfile(context.Background(), path, nil, options...); err != nil {
+		t.Fatalf("WriteLockfile() error = %v", err)
+	}
+
+	// kind is cached before, e.g. by an older setup or a broken bundle.
+	cached := filepath.Join(cacheDir, "kind", "0.11.0", runtime.GOOS+"-"+runtime.GOARCH, "kind")
+	if err := os.MkdirAll(filepath.Dir(cached), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cached, []byte("#!/bin/sh\necho kind v0.11.0 tampered\n"), 0o755); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	_, err := setup.Start(context.Background(), append(options, setup.WithLockfile(path))...)
+	var checksumErr *cli.ChecksumError
+	if !errors.As(err, &checksumErr) || checksumErr.Name != "kind" {
+		t.Errorf("Start() error = %v, want *cli.ChecksumError of kind", err)
+	}
+}
@@ -0,0 +1,39 @@
+package setup_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(func() int {
+		// Start records the kind config of the cluster in the cache, which must not leak into the cache of the user.
+		cacheDir, err := os.MkdirTemp("", "ket-cache-")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer os.RemoveAll(cacheDir)
+		if err := os.Setenv(cli.CacheDirEnv, cacheDir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return m.Run()
+	}())
+}
+
+func TestTestDeadline(t *testing.T) {
+	// go test runs with -timeout 10m by default.
+	deadline, ok := setup.TestDeadline(time.Minute)
+	if !ok {
+		t.Skip("go test runs without -timeout")
+	}
+	if remaining := time.Until(deadline); remaining <= 0 {
+		t.Errorf("TestDeadline() = %s, want a deadline in the future", deadline)
+	}
+}
@@ -0,0 +1,45 @@
+package setup
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+// phase runs fn as a phase of the setup, e.g. "create cluster", and reports it with its duration.
+// The commands executed in fn are logged with the phase field and recorded in the transcript as executed in the phase.
+func (k *KET) phase(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx = cli.ContextWithPhase(ctx, name)
+	logger := k.logging.Logger
+	if logger != nil {
+		logger = logger.WithValues("phase", name)
+		ctx = logr.NewContext(ctx, logger)
+		logger.Info("starting phase")
+	}
+
+	started := time.Now()
+	err := fn(ctx)
+	duration := time.Since(started)
+	if logger != nil {
+		if err != nil {
+			logger.Error(err, "failed phase", "duration", duration)
+		} else {
+			logger.Info("finished phase", "duration", duration)
+		}
+	}
+	if k.logging.Transcript != nil {
+		entry := cli.TranscriptEntry{
+			Kind:     cli.EntryPhase,
+			Name:     name,
+			Start:    started,
+			Duration: duration,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		k.logging.Transcript.Record(entry)
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package setup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kind"
+	"github.com/riita10069/ket/pkg/kubectl"
+)
+
+// WithReuseCluster reuses the existing kind cluster if it is healthy, runs the Kubernetes version
+// and is created with the same kind config, extra args and patches, instead of deleting and creating it. The CRDs are applied and skaffold deploys the controller even so.
+func WithReuseCluster() Option {
+	return func(k *KET) error {
+		k.reuseCluster = true
+		return nil
+	}
+}
+
+// WithRecreateCluster always deletes and creates the kind cluster. It is the default.
+func WithRecreateCluster() Option {
+	return func(k *KET) error {
+		k.reuseCluster = false
+		return nil
+	}
+}
+
+// checkCluster reports whether the existing cluster can be reused, and writes its kubeconfig if so.
+// configHash is the hash of the kind config to create the cluster with, which is compared with the one recorded on creation.
+func (k *KET) checkCluster(ctx context.Context, kind *kind.Kind, kubectl *kubectl.Kubectl, configHash string) (bool, error) {
+	logger := logr.FromContext(ctx)
+	if logger == nil {
+		logger = logr.Discard()
+	}
+
+	clusters, err := kind.GetClusters(ctx)
+	if err != nil {
+		return false, err
+	}
+	found := false
+	for _, cluster := range clusters {
+		if cluster == k.kindClusterName {
+			found = true
+		}
+	}
+	if !found {
+		logger.Info("recreating cluster", "reason", "cluster is not found")
+		return false, nil
+	}
+	recorded, err := readConfigHash(k.kindClusterName)
+	if err != nil {
+		return false, err
+	}
+	if recorded != configHash {
+		logger.Info("recreating cluster", "reason", "kind config is changed")
+		return false, nil
+	}
+
+	if err := kind.ExportKubeconfig(ctx, k.kindClusterName); err != nil {
+		logger.Info("recreating cluster", "reason", err.Error())
+		return false, nil
+	}
+	if err := kubectl.Readyz(ctx); err != nil {
+		logger.Info("recreating cluster", "reason", err.Error())
+		return false, nil
+	}
+	version, err := kubectl.ServerVersion(ctx)
+	if err != nil {
+		logger.Info("recreating cluster", "reason", err.Error())
+		return false, nil
+	}
+	if strings.TrimPrefix(version, "v") != strings.TrimPrefix(k.kubernetesVersion, "v") {
+		logger.Info("recreating cluster", "reason", "kubernetes version is "+version)
+		return false, nil
+	}
+	if err := k.verifyControlPlane(ctx, kubectl); err != nil {
+		logger.Info("recreating cluster", "reason", err.Error())
+		return false, nil
+	}
+	logger.Info("reusing cluster", "cluster", k.kindClusterName, "version", version)
+	return true, nil
+}
+
+// hashConfig returns the hash of the rendered kind config, or an empty string if the cluster is created without any config.
+func hashConfig(config *kind.Cluster) (string, error) {
+	if config == nil {
+		return "", nil
+	}
+	b, err := config.YAML()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// configHashPath returns the file in the cache recording the hash of the kind config the cluster is created with.
+func configHashPath(clusterName string) (string, error) {
+	cacheDir, err := cli.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "clusters", clusterName), nil
+}
+
+// readConfigHash returns the hash recorded by writeConfigHash.
+// It is empty for a cluster created without recording it, which is assumed to have the default config.
+func readConfigHash(clusterName string) (string, error) {
+	path, err := configHashPath(clusterName)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read hash of kind config: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func writeConfigHash(clusterName, hash string) error {
+	path, err := configHashPath(clusterName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to record hash of kind config: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hash+"\n"), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("failed to record hash of kind config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package setup_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/kind"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://127.0.0.1:6443
+  name: kind-ket
+contexts:
+- context:
+    cluster: kind-ket
+    user: kind-ket
+  name: kind-ket
+current-context: kind-ket
+users:
+- name: kind-ket
+  user:
+    token: token
+`
+
+func TestStartReuseCluster(t *testing.T) {
+	tests := []struct {
+		name       string
+		clusters   string
+		version    string
+		wantReused bool
+	}{
+		{name: "healthy cluster", clusters: "ket\n", version: "v1.20.2", wantReused: true},
+		{name: "cluster is not found", clusters: "other\n"},
+		{name: "kubernetes version mismatch", clusters: "ket\n", version: "v1.19.1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, cli.CacheDirEnv, t.TempDir())
+			path := filepath.Join(t.TempDir(), "kubeconfig")
+			if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			fake := kettest.NewFakeExecutor(t)
+			fake.Expect("kind", "get", "clusters").Return(tt.clusters, "", 0)
+			if tt.version != "" {
+				fake.Expect("kind", "export", "kubeconfig", "--name", "ket", "--kubeconfig", path)
+				fake.Expect("kubectl", "get", "--raw", "/readyz").Return("ok", "", 0)
+				fake.Expect("kubectl", "version", "-o", "json").Return(`{"serverVersion":{"gitVersion":"`+tt.version+`"}}`, "", 0)
+			}
+			if !tt.wantReused {
+				fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", path)
+				fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", "kindest/node:v1.20.2", "--kubeconfig", path)
+			}
+			fake.Expect("kubectl", "config", "use-context", "kind-ket")
+
+			cliSet, err := setup.Start(
+				context.Background(),
+				setup.WithExecutor(fake),
+				setup.WithKubeconfigPath(path),
+				setup.WithKubernetesVersion("1.20.2"),
+				setup.WithReuseCluster(),
+			)
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if cliSet.ReusedCluster != tt.wantReused {
+				t.Errorf("ReusedCluster = %v, want %v", cliSet.ReusedCluster, tt.wantReused)
+			}
+		})
+	}
+}
+
+func TestStartReuseClusterWithKindConfig(t *testing.T) {
+	setEnv(t, cli.CacheDirEnv, t.TempDir())
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	oneWorker := kind.Cluster{Nodes: []kind.Node{{Role: kind.ControlPlaneRole}, {Role: kind.WorkerRole}}}
+	twoWorkers := kind.Cluster{Nodes: []kind.Node{{Role: kind.ControlPlaneRole}, {Role: kind.WorkerRole}, {Role: kind.WorkerRole}}}
+
+	for _, run := range []struct {
+		name       string
+		config     kind.Cluster
+		clusters   string
+		wantReused bool
+	}{
+		{name: "create cluster", config: oneWorker},
+		{name: "same kind config", config: oneWorker, clusters: "ket\n", wantReused: true},
+		{name: "kind config is changed", config: twoWorkers, clusters: "ket\n"},
+	} {
+		fake := kettest.NewFakeExecutor(t)
+		fake.Expect("kind", "get", "clusters").Return(run.clusters, "", 0)
+		if run.wantReused {
+			fake.Expect("kind", "export", "kubeconfig", "--name", "ket", "--kubeconfig", path)
+			fake.Expect("kubectl", "get", "--raw", "/readyz").Return("ok", "", 0)
+			fake.Expect("kubectl", "version", "-o", "json").Return(`{"serverVersion":{"gitVersion":"v1.20.2"}}`, "", 0)
+		} else {
+			fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", path)
+			fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", "kindest/node:v1.20.2", "--kubeconfig", path, "--config", kettest.Any)
+		}
+		fake.Expect("kubectl", "config", "use-context", "kind-ket")
+
+		cliSet, err := setup.Start(
+			context.Background(),
+			setup.WithExecutor(fake),
+			setup.WithKubeconfigPath(path),
+			setup.WithKindConfig(run.config),
+			setup.WithReuseCluster(),
+		)
+		if err != nil {
+			t.Fatalf("%s: Start() error = %v", run.name, err)
+		}
+		if cliSet.ReusedCluster != run.wantReused {
+			t.Errorf("%s: ReusedCluster = %v, want %v", run.name, cliSet.ReusedCluster, run.wantReused)
+		}
+	}
+}
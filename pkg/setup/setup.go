@@ -2,11 +2,17 @@ package setup
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/riita10069/ket/pkg/cli"
 	"github.com/riita10069/ket/pkg/k8s"
 	"github.com/riita10069/ket/pkg/kind"
 	"github.com/riita10069/ket/pkg/kubectl"
@@ -36,6 +42,14 @@ func WithKindClusterName(kindClusterName string) Option {
 	}
 }
 
+// WithKindConfig creates the cluster with the kind configuration, e.g. worker nodes, extra port mappings or feature gates.
+func WithKindConfig(config kind.Cluster) Option {
+	return func(k *KET) error {
+		k.kindConfig = &config
+		return nil
+	}
+}
+
 func WithKubernetesVersion(kubernetesVersion string) Option {
 	return func(k *KET) error {
 		k.kubernetesVersion = kubernetesVersion
@@ -64,6 +78,14 @@ func WithCRDKustomizePath(crdKustomizePath string) Option {
 	}
 }
 
+// WithCRDTimeout bounds the wait for the CRDs applied by Start to be established and served. The default is 1 minute.
+func WithCRDTimeout(timeout time.Duration) Option {
+	return func(k *KET) error {
+		k.crdTimeout = timeout
+		return nil
+	}
+}
+
 func WithUseSkaffold() Option {
 	return func(k *KET) error {
 		k.useSkaffold = true
@@ -85,17 +107,209 @@ func WithSkaffoldYaml(skaffoldYaml string) Option {
 	}
 }
 
+// WithHTTPClient downloads the binaries with the client, e.g. one trusting the custom CA of a proxy.
+func WithHTTPClient(client *http.Client) Option {
+	return func(k *KET) error {
+		k.download.HTTPClient = client
+		return nil
+	}
+}
+
+// WithMirrorURL downloads the binaries from the mirror with the upstream path preserved.
+// file:// URLs are also supported.
+func WithMirrorURL(mirrorURL string) Option {
+	return func(k *KET) error {
+		if _, err := url.Parse(mirrorURL); err != nil {
+			return fmt.Errorf("invalid mirror url %s: %w", mirrorURL, err)
+		}
+		k.download.MirrorURL = mirrorURL
+		return nil
+	}
+}
+
+// WithDownloadHeader adds the header to the download requests to the host, e.g. a GitHub token for "github.com".
+// If host is empty, the header is added to the requests to every host.
+func WithDownloadHeader(host, key, value string) Option {
+	return func(k *KET) error {
+		if k.download.Headers == nil {
+			k.download.Headers = map[string]http.Header{}
+		}
+		if k.download.Headers[host] == nil {
+			k.download.Headers[host] = http.Header{}
+		}
+		k.download.Headers[host].Add(key, value)
+		return nil
+	}
+}
+
+// WithResolvePolicy decides whether kind, kubectl and skaffold installed on PATH are used instead of downloading them.
+// By default, cli.DownloadOnly is used.
+func WithResolvePolicy(policy cli.ResolvePolicy) Option {
+	return func(k *KET) error {
+		switch policy {
+		case cli.DownloadOnly, cli.PreferSystem, cli.Strict:
+		default:
+			return fmt.Errorf("unknown resolve policy %q", policy)
+		}
+		k.download.Policy = policy
+		return nil
+	}
+}
+
+// WithVersionCheck decides when the version of kind, kubectl, skaffold and the tools is verified with their version command.
+// By default, cli.CheckAfterDownload is used.
+func WithVersionCheck(check cli.VersionCheck) Option {
+	return func(k *KET) error {
+		switch check {
+		case cli.CheckAfterDownload, cli.CheckOnGet, cli.CheckNever:
+		default:
+			return fmt.Errorf("unknown version check %q", check)
+		}
+		k.download.VersionCheck = check
+		return nil
+	}
+}
+
+// WithExecutor replaces the Executor of kind, kubectl and skaffold commands, e.g. with a fake in unit tests.
+func WithExecutor(executor cli.Executor) Option {
+	return func(k *KET) error {
+		k.executor = executor
+		return nil
+	}
+}
+
+// WithTimeout bounds every invocation of kind, kubectl and skaffold commands. The default is 10 minutes.
+func WithTimeout(timeout time.Duration) Option {
+	return func(k *KET) error {
+		k.timeouts.Default = timeout
+		return nil
+	}
+}
+
+// WithCommandTimeout bounds the invocations of the command, e.g. "kind create cluster" or "kubectl apply".
+func WithCommandTimeout(command string, timeout time.Duration) Option {
+	return func(k *KET) error {
+		if len(strings.Fields(command)) == 0 {
+			return fmt.Errorf("command is empty")
+		}
+		if k.timeouts.Commands == nil {
+			k.timeouts.Commands = map[string]time.Duration{}
+		}
+		k.timeouts.Commands[command] = timeout
+		return nil
+	}
+}
+
+// WithDeadline bounds every invocation by the deadline, e.g. the one of TestDeadline,
+// so that a hung command fails with the command line before go test panics.
+func WithDeadline(deadline time.Time) Option {
+	return func(k *KET) error {
+		k.timeouts.Deadline = deadline
+		return nil
+	}
+}
+
+// TestDeadline returns the -timeout of go test from now minus margin, and false if there is no timeout.
+// Call it in TestMain after flag.Parse. The timeout counts from m.Run, so the deadline is earlier than the one of go test.
+func TestDeadline(margin time.Duration) (time.Time, bool) {
+	if !flag.Parsed() {
+		return time.Time{}, false
+	}
+	f := flag.Lookup("test.timeout")
+	if f == nil {
+		return time.Time{}, false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return time.Time{}, false
+	}
+	timeout, ok := getter.Get().(time.Duration)
+	if !ok || timeout <= 0 {
+		return time.Time{}, false
+	}
+	return time.Now().Add(timeout - margin), true
+}
+
+// WithLogger reports the setup phases and the invocations of kind, kubectl and skaffold to the logger.
+// The verbose output of the tools is logged at cli.LevelOutput instead of os.Stdout and os.Stderr.
+func WithLogger(logger logr.Logger) Option {
+	return func(k *KET) error {
+		k.logging.Logger = logger
+		return nil
+	}
+}
+
+// WithLogFile writes the verbose output of kind, kubectl and skaffold to the file instead of os.Stdout and os.Stderr.
+func WithLogFile(logFile string) Option {
+	return func(k *KET) error {
+		k.logFile = logFile
+		return nil
+	}
+}
+
+// WithTranscript records the setup phases and the invocations of kind, kubectl and skaffold in the transcript.
+// By default, Start records them in a new transcript exposed by ClientSet.Transcript.
+// Passing one is useful to keep the transcript of a failed setup.
+func WithTranscript(transcript *cli.Transcript) Option {
+	return func(k *KET) error {
+		k.logging.Transcript = transcript
+		return nil
+	}
+}
+
+// WithTool downloads and caches the tool, e.g. helm or kustomize, as kind, kubectl and skaffold are.
+// The tool is exposed by ClientSet.Tools with its name, which must not be kind, kubectl, skaffold or another tool.
+func WithTool(spec cli.ToolSpec) Option {
+	return func(k *KET) error {
+		switch spec.Name {
+		case "kind", "kubectl", "skaffold":
+			return fmt.Errorf("tool %s conflicts with the one of ket", spec.Name)
+		}
+		for _, s := range k.tools {
+			if s.Name == spec.Name {
+				return fmt.Errorf("tool %s is already added", spec.Name)
+			}
+		}
+		k.tools = append(k.tools, spec)
+		return nil
+	}
+}
+
+// installsBinaries reports whether the commands are executed by cli.OSExecutor, which installs the binaries.
+func (k *KET) installsBinaries() bool {
+	switch k.executor.(type) {
+	case nil, cli.OSExecutor, *cli.OSExecutor:
+		return true
+	}
+	return false
+}
+
 type KET struct {
-	binDir            string
-	kindVersion       string
-	kindClusterName   string
-	kubernetesVersion string
-	kubeconfigPath    string
-	isThereCRD        bool
-	crdKustomizePath  string
-	useSkaffold       bool
-	skaffoldVersion   string
-	skaffoldYaml      string
+	binDir               string
+	kindVersion          string
+	kindClusterName      string
+	kindConfig           *kind.Cluster
+	controlPlane         kind.ControlPlane
+	kubeadmConfigPatches []string
+	kubernetesVersion    string
+	kubeconfigPath       string
+	isThereCRD           bool
+	crdKustomizePath     string
+	useSkaffold          bool
+	skaffoldVersion      string
+	skaffoldYaml         string
+	download             cli.DownloadConfig
+	executor             cli.Executor
+	timeouts             cli.Timeouts
+	logging              cli.Logging
+	logFile              string
+	tools                []cli.ToolSpec
+	lockfile             string
+	reuseCluster         bool
+	keepCluster          bool
+	skaffoldDelete       bool
+	tempKubeconfig       bool
+	crdTimeout           time.Duration
 }
 
 func NewKET() *KET {
@@ -114,6 +328,10 @@ func NewKET() *KET {
 		useSkaffold:       false,
 		skaffoldVersion:   "1.26.1",
 		skaffoldYaml:      "./skaffold/skaffold.yaml",
+		crdTimeout:        time.Minute,
+		timeouts: cli.Timeouts{
+			Default: 10 * time.Minute,
+		},
 	}
 }
 
@@ -122,9 +340,24 @@ type ClientSet struct {
 	Kubectl  *kubectl.Kubectl
 	Kind     *kind.Kind
 	Skaffold *skaffold.Skaffold
+	// SkaffoldProcess is skaffold dev running in the background if WithUseSkaffold is used.
+	SkaffoldProcess *cli.Process
+	// Tools are the tools added by WithTool, keyed by their name.
+	Tools map[string]*cli.Tool
+	// ReusedCluster is true if the existing cluster is reused by WithReuseCluster.
+	ReusedCluster bool
+	// Versions are the versions of kind, kubectl, skaffold and the tools used by the setup.
+	Versions []ToolVersion
+	// Transcript records the setup phases and the invocations of the commands, e.g. for a timing report in TestMain.
+	Transcript *cli.Transcript
+
+	ket     *KET
+	logFile *os.File
+	tempDir string
+	closed  bool
 }
 
-func Start(ctx context.Context, options ...Option) (*ClientSet, error) {
+func Start(ctx context.Context, options ...Option) (_ *ClientSet, err error) {
 	ket := NewKET()
 	for _, option := range options {
 		err := option(ket)
@@ -133,18 +366,163 @@ func Start(ctx context.Context, options ...Option) (*ClientSet, error) {
 		}
 	}
 
-	cliSet := &ClientSet{}
-	kind := kind.NewKind(ket.kindVersion, ket.kubernetesVersion, ket.binDir, ket.kubeconfigPath)
-	cliSet.Kind = kind
-
-	err := kind.DeleteCluster(ctx, ket.kindClusterName)
+	setupStarted := time.Now()
+	pins, nodeImage, err := ket.pins()
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete kind cluster %s: %w", ket.kindClusterName, err)
+		return nil, fmt.Errorf("failed to verify the lockfile: %w", err)
+	}
+	if nodeImage != "" {
+		nodeImage = localNodeImage(ctx, nodeImage)
 	}
 
-	err = kind.CreateCluster(ctx, ket.kindClusterName)
+	if ket.logging.Transcript == nil {
+		ket.logging.Transcript = cli.NewTranscript()
+	}
+	cliSet := &ClientSet{
+		Transcript: ket.logging.Transcript,
+		ket:        ket,
+	}
+	defer func() {
+		// The caller can't Close the ClientSet if Start fails.
+		if err != nil {
+			_ = cliSet.release()
+		}
+	}()
+	if ket.tempKubeconfig {
+		tempDir, err := os.MkdirTemp("", "ket-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary directory for kubeconfig: %w", err)
+		}
+		cliSet.tempDir = tempDir
+		ket.kubeconfigPath = filepath.Join(tempDir, "kubeconfig")
+	}
+	if ket.logFile != "" {
+		logFile, err := os.OpenFile(ket.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		cliSet.logFile = logFile
+		ket.logging.Output = logFile
+	}
+
+	if len(ket.tools) > 0 {
+		cliSet.Tools = map[string]*cli.Tool{}
+		for _, spec := range ket.tools {
+			if pin, ok := pins[spec.Name]; ok {
+				spec.SHA256 = pin.SHA256
+			}
+			tool, err := cli.NewTool(
+				spec,
+				ket.binDir,
+				cli.WithToolDownloadConfig(&ket.download),
+				cli.WithToolExecutor(ket.executor),
+				cli.WithToolTimeouts(&ket.timeouts),
+				cli.WithToolLogging(&ket.logging),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create tool %s: %w", spec.Name, err)
+			}
+			if err := ket.verifyPin(ctx, tool, pins); err != nil {
+				return nil, err
+			}
+			cliSet.Tools[spec.Name] = tool
+		}
+	}
+	// Download the tools before creating the cluster so that a broken spec fails fast.
+	// Another executor, e.g. kettest.FakeExecutor, doesn't need the binaries, as with kind, kubectl and skaffold.
+	if len(ket.tools) > 0 && ket.installsBinaries() {
+		err := ket.phase(ctx, "get tools", func(ctx context.Context) error {
+			for _, spec := range ket.tools {
+				if err := cli.Get(ctx, cliSet.Tools[spec.Name]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tools: %w", err)
+		}
+	}
+
+	kindConfig, err := ket.clusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure kind cluster: %w", err)
+	}
+	configHash, err := hashConfig(kindConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create kind cluster %s: %w", ket.kindClusterName, err)
+		return nil, fmt.Errorf("failed to configure kind cluster: %w", err)
+	}
+	kind := kind.NewKind(
+		ket.kindVersion,
+		ket.kubernetesVersion,
+		ket.binDir,
+		ket.kubeconfigPath,
+		kind.WithDownloadConfig(&ket.download),
+		kind.WithExecutor(ket.executor),
+		kind.WithTimeouts(&ket.timeouts),
+		kind.WithLogging(&ket.logging),
+		kind.WithSHA256(pins["kind"].SHA256),
+		kind.WithNodeImage(nodeImage),
+		kind.WithConfig(kindConfig),
+	)
+	if err := ket.verifyPin(ctx, kind, pins); err != nil {
+		return nil, err
+	}
+	cliSet.Kind = kind
+
+	kubectl := kubectl.NewKubectl(
+		ket.kubernetesVersion,
+		ket.binDir,
+		ket.kubeconfigPath,
+		kubectl.WithDownloadConfig(&ket.download),
+		kubectl.WithExecutor(ket.executor),
+		kubectl.WithTimeouts(&ket.timeouts),
+		kubectl.WithLogging(&ket.logging),
+		kubectl.WithSHA256(pins["kubectl"].SHA256),
+	)
+	if err := ket.verifyPin(ctx, kubectl, pins); err != nil {
+		return nil, err
+	}
+	cliSet.Kubectl = kubectl
+
+	if ket.reuseCluster {
+		err = ket.phase(ctx, "check cluster", func(ctx context.Context) error {
+			var err error
+			cliSet.ReusedCluster, err = ket.checkCluster(ctx, kind, kubectl, configHash)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check kind cluster %s: %w", ket.kindClusterName, err)
+		}
+	}
+
+	if !cliSet.ReusedCluster {
+		err = ket.phase(ctx, "delete cluster", func(ctx context.Context) error {
+			return kind.DeleteCluster(ctx, ket.kindClusterName)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete kind cluster %s: %w", ket.kindClusterName, err)
+		}
+
+		err = ket.phase(ctx, "create cluster", func(ctx context.Context) error {
+			return kind.CreateCluster(ctx, ket.kindClusterName)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kind cluster %s: %w", ket.kindClusterName, err)
+		}
+		// WithReuseCluster of a later run compares the config with it.
+		if err := writeConfigHash(ket.kindClusterName, configHash); err != nil {
+			return nil, err
+		}
+
+		if !ket.controlPlane.IsZero() {
+			err = ket.phase(ctx, "verify control plane", func(ctx context.Context) error {
+				return ket.verifyControlPlane(ctx, kubectl)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to verify control plane of kind cluster %s: %w", ket.kindClusterName, err)
+			}
+		}
 	}
 
 	clientGo, err := k8s.NewClientGo(ket.kubeconfigPath)
@@ -153,34 +531,67 @@ func Start(ctx context.Context, options ...Option) (*ClientSet, error) {
 	}
 	cliSet.ClientGo = clientGo
 
-	kubectl := kubectl.NewKubectl(ket.kubernetesVersion, ket.binDir, ket.kubeconfigPath)
-	cliSet.Kubectl = kubectl
-
-	err = kubectl.UseContext(ctx, ket.kindClusterName)
+	err = ket.phase(ctx, "use context", func(ctx context.Context) error {
+		return kubectl.UseContext(ctx, ket.kindClusterName)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to use context: %w", err)
 	}
 
 	if ket.isThereCRD {
-		err = kubectl.ApplyKustomize(ctx, ket.crdKustomizePath)
+		var crds []string
+		err = ket.phase(ctx, "apply crd", func(ctx context.Context) error {
+			var err error
+			crds, err = kubectl.ApplyKustomizeCRDs(ctx, ket.crdKustomizePath)
+			return err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to apply crd yaml: %w", err)
 		}
-	}
 
-	// TODO
-	// Waiting for resources to be applied by kustomize Just before.
-	// It should be guaranteed that the resource is created.
-	time.Sleep(3 * time.Second)
+		if len(crds) > 0 {
+			err = ket.phase(ctx, "wait crd", func(ctx context.Context) error {
+				ctx, cancel := context.WithTimeout(ctx, ket.crdTimeout)
+				defer cancel()
+				return kubectl.WaitCRDs(ctx, crds)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to wait for crds: %w", err)
+			}
+		}
+	}
 
 	if ket.useSkaffold {
-		skaffold := skaffold.NewSkaffold(ket.skaffoldVersion, ket.binDir, ket.kubeconfigPath)
+		skaffold := skaffold.NewSkaffold(
+			ket.skaffoldVersion,
+			ket.binDir,
+			ket.kubeconfigPath,
+			skaffold.WithDownloadConfig(&ket.download),
+			skaffold.WithExecutor(ket.executor),
+			skaffold.WithTimeouts(&ket.timeouts),
+			skaffold.WithLogging(&ket.logging),
+			skaffold.WithSHA256(pins["skaffold"].SHA256),
+		)
+		if err := ket.verifyPin(ctx, skaffold, pins); err != nil {
+			return nil, err
+		}
 		cliSet.Skaffold = skaffold
-		err = skaffold.Run(ctx, ket.skaffoldYaml, false)
+		err = ket.phase(ctx, "skaffold deploy", func(ctx context.Context) error {
+			process, err := skaffold.Deploy(ctx, ket.skaffoldYaml, false)
+			if err != nil {
+				return err
+			}
+			cliSet.SkaffoldProcess = process
+			return nil
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to skaffold run: %w", err)
 		}
 	}
 
+	cliSet.Versions = versionsOf(cliSet)
+	if logger := ket.logging.Logger; logger != nil {
+		logger.Info("finished setup", "duration", time.Since(setupStarted), "versions", cliSet.Summary())
+	}
 	return cliSet, nil
 }
@@ -0,0 +1,66 @@
+package setup_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+func TestStartWithSkaffold(t *testing.T) {
+	tests := []struct {
+		name     string
+		stdout   string
+		exitCode int
+		wantErr  string
+	}{
+		{
+			name:   "stabilized",
+			stdout: "Generating tags...\nDeployments stabilized in 1.2 seconds\nWatching for changes...\n",
+		},
+		{
+			name:     "exited before stabilized",
+			stdout:   "Generating tags...\n",
+			exitCode: 1,
+			wantErr:  "exited before the deployments are stabilized",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "kubeconfig")
+			if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			fake := kettest.NewFakeExecutor(t)
+			fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", path)
+			fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", "kindest/node:v1.20.2", "--kubeconfig", path)
+			fake.Expect("kubectl", "config", "use-context", "kind-ket")
+			fake.Expect("skaffold", "dev", "-f", "./skaffold/skaffold.yaml", "--port-forward").Return(tt.stdout, "", tt.exitCode)
+			transcript := cli.NewTranscript()
+
+			cliSet, err := setup.Start(context.Background(),
+				setup.WithExecutor(fake), setup.WithKubeconfigPath(path), setup.WithUseSkaffold(), setup.WithTranscript(transcript))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Start() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			} else if cliSet.SkaffoldProcess == nil {
+				t.Error("SkaffoldProcess is nil")
+			}
+
+			entries := transcript.Entries()
+			deploy := entries[len(entries)-1]
+			if deploy.Kind != cli.EntryPhase || deploy.Name != "skaffold deploy" || (deploy.Error != "") != (tt.wantErr != "") {
+				t.Errorf("the last entry = %+v, want the skaffold deploy phase", deploy)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package setup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/riita10069/ket/pkg/cli"
+)
+
+// ToolVersion is the version of a tool used by the setup.
+type ToolVersion struct {
+	Name string
+	// Version is the version the tool is pinned to.
+	Version string
+	// Verified is the version reported by the version command of the installed binary,
+	// or empty if it hasn't been verified, e.g. with cli.CheckNever or a fake executor.
+	Verified string
+	Path     string
+}
+
+func versionsOf(cliSet *ClientSet) []ToolVersion {
+	clis := []cli.CLI{cliSet.Kind, cliSet.Kubectl}
+	if cliSet.Skaffold != nil {
+		clis = append(clis, cliSet.Skaffold)
+	}
+	names := make([]string, 0, len(cliSet.Tools))
+	for name := range cliSet.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		clis = append(clis, cliSet.Tools[name])
+	}
+
+	versions := make([]ToolVersion, 0, len(clis))
+	for _, c := range clis {
+		versions = append(versions, ToolVersion{
+			Name:     c.Name(),
+			Version:  c.Version(),
+			Verified: cli.VerifiedVersion(c),
+			Path:     c.Path(),
+		})
+	}
+	return versions
+}
+
+// Summary returns the versions of the tools used by the setup,
+// e.g. "kind 0.11.0 (verified), kubectl 1.20.2 (unverified)".
+func (c *ClientSet) Summary() string {
+	summaries := make([]string, 0, len(c.Versions))
+	for _, v := range c.Versions {
+		switch v.Verified {
+		case "":
+			summaries = append(summaries, fmt.Sprintf("%s %s (unverified)", v.Name, v.Version))
+		case v.Version:
+			summaries = append(summaries, fmt.Sprintf("%s %s (verified)", v.Name, v.Version))
+		default:
+			summaries = append(summaries, fmt.Sprintf("%s %s (verified %s)", v.Name, v.Version, v.Verified))
+		}
+	}
+	return strings.Join(summaries, ", ")
+}
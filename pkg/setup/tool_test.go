@@ -0,0 +1,61 @@
+package setup_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riita10069/ket/pkg/cli"
+	"github.com/riita10069/ket/pkg/kettest"
+	"github.com/riita10069/ket/pkg/setup"
+)
+
+// helm is downloaded from nowhere, which fails if Start tries to.
+var helm = cli.ToolSpec{
+	Name:    "helm",
+	Version: "3.6.3",
+	URL:     "http://127.0.0.1:0/helm-v{{.Version}}-{{.OS}}-{{.Arch}}",
+}
+
+func TestStartWithToolAndFakeExecutor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fake := kettest.NewFakeExecutor(t)
+	fake.Expect("kind", "delete", "cluster", "--name", "ket", "--kubeconfig", path)
+	fake.Expect("kind", "create", "cluster", "--name", "ket", "--image", "kindest/node:v1.20.2", "--kubeconfig", path)
+	fake.Expect("kubectl", "config", "use-context", "kind-ket")
+	fake.Expect("helm", "version")
+
+	cliSet, err := setup.Start(context.Background(), setup.WithExecutor(fake), setup.WithKubeconfigPath(path), setup.WithTool(helm))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := cliSet.Tools["helm"].Execute(context.Background(), []string{"version"}); err != nil {
+		t.Errorf("Execute() error = %v", err)
+	}
+}
+
+func TestWithToolConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []setup.Option
+	}{
+		{name: "tool of ket", options: []setup.Option{setup.WithTool(cli.ToolSpec{Name: "kubectl", Version: "1.21.1", URL: "https://example.com/kubectl"})}},
+		{name: "another tool", options: []setup.Option{setup.WithTool(helm), setup.WithTool(helm)}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Start fails before executing any command.
+			options := append([]setup.Option{setup.WithExecutor(kettest.NewFakeExecutor(t))}, tt.options...)
+			_, err := setup.Start(context.Background(), options...)
+			if err == nil || !strings.Contains(err.Error(), "failed to run options") {
+				t.Errorf("Start() error = %v, want the conflict", err)
+			}
+		})
+	}
+}
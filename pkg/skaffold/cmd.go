@@ -2,12 +2,17 @@ package skaffold
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/riita10069/ket/pkg/cli"
 )
 
-// Run exec skaffold run -f {filename}
+// Run exec skaffold dev -f {filename} in the background.
 // if logs is true, it outputs kubectl logs to stdout.
-func (s *Skaffold) Run(ctx context.Context, filename string, logs bool) error {
+// The returned process is stopped gracefully when ctx is done or by Process.Stop.
+func (s *Skaffold) Run(ctx context.Context, filename string, logs bool, opts ...cli.ProcessOption) (*cli.Process, error) {
 	args := []string{
 		"dev",
 		"-f",
@@ -19,13 +24,66 @@ func (s *Skaffold) Run(ctx context.Context, filename string, logs bool) error {
 		args = append(args, "--tail")
 	}
 
-	go func(ctx context.Context) {
-		if err := s.Execute(ctx, args); err != nil {
-			// fmt.Printf("failed to exec %v\n build or deploy resource of %s: %v", args, filename, err)
-			// FIXME: We should not allow this goroutine to cause a selfish panic.
-			time.Sleep(10 * time.Millisecond)
-			panic(err)
+	opts = append([]cli.ProcessOption{cli.WithLineHandler(cli.OutputLine(ctx, s))}, opts...)
+	process, err := s.Start(ctx, args, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build or deploy resource of %s: %w", filename, err)
+	}
+	return process, nil
+}
+
+// stabilizedMessage is printed by skaffold dev when the deployed resources have become ready.
+const stabilizedMessage = "Deployments stabilized"
+
+// Deploy exec skaffold dev as Run does, and waits until skaffold reports that the deployments are stabilized.
+// The returned process keeps running, e.g. to redeploy on changes, and is stopped as the one of Run.
+// The output is reported as Run does even if opts contain cli.WithLineHandler.
+func (s *Skaffold) Deploy(ctx context.Context, filename string, logs bool, opts ...cli.ProcessOption) (*cli.Process, error) {
+	stabilized := make(chan struct{})
+	var once sync.Once
+	output := cli.OutputLine(ctx, s)
+	onLine := func(line cli.Line) {
+		output(line)
+		if strings.Contains(line.Text, stabilizedMessage) {
+			once.Do(func() { close(stabilized) })
+		}
+	}
+	process, err := s.Run(ctx, filename, logs, append(opts, cli.WithLineHandler(onLine))...)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-stabilized:
+		return process, nil
+	case <-process.Done():
+		// The line is handled before the command exits.
+		select {
+		case <-stabilized:
+			return process, nil
+		default:
+		}
+		if err := process.Wait(); err != nil {
+			return nil, fmt.Errorf("skaffold dev of %s exited before the deployments are stabilized: %w", filename, err)
 		}
-	}(ctx)
+		return nil, fmt.Errorf("skaffold dev of %s exited before the deployments are stabilized", filename)
+	case <-ctx.Done():
+		_ = process.Stop()
+		return nil, fmt.Errorf("deployments of %s are not stabilized: %w", filename, ctx.Err())
+	}
+}
+
+// Delete deletes the resources deployed by skaffold.
+func (s *Skaffold) Delete(ctx context.Context, filename string) error {
+	args := []string{
+		"delete",
+		"-f",
+		filename,
+	}
+
+	err := s.Execute(ctx, args)
+	if err != nil {
+		return fmt.Errorf("failed to delete resource of %s: %w", filename, err)
+	}
 	return nil
 }
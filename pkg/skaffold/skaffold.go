@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
+	"strings"
 
 	"github.com/riita10069/ket/pkg/cli"
 )
@@ -16,16 +16,71 @@ type Skaffold struct {
 	binDir         string
 	kubeConfigPath string
 	url            string
+	logging        *cli.Logging
+	timeouts       *cli.Timeouts
+	executor       cli.Executor
+	download       *cli.DownloadConfig
+	platform       cli.Platform
+	sha256         string
 }
 
-func NewSkaffold(version, binDir, kubeConfigPath string) *Skaffold {
-	return &Skaffold{
+type Option func(*Skaffold)
+
+// WithSHA256 pins the expected SHA-256 of the binary instead of fetching the published checksum file.
+func WithSHA256(sum string) Option {
+	return func(s *Skaffold) {
+		s.sha256 = sum
+	}
+}
+
+// WithDownloadConfig configures how the binary is downloaded.
+func WithDownloadConfig(config *cli.DownloadConfig) Option {
+	return func(s *Skaffold) {
+		s.download = config
+	}
+}
+
+// WithPlatform downloads the binary for the platform instead of the host, e.g. to export a bundle.
+func WithPlatform(platform cli.Platform) Option {
+	return func(s *Skaffold) {
+		s.platform = platform
+	}
+}
+
+// WithExecutor replaces the Executor of the commands, e.g. with a fake in unit tests.
+func WithExecutor(executor cli.Executor) Option {
+	return func(s *Skaffold) {
+		s.executor = executor
+	}
+}
+
+// WithTimeouts bounds every invocation of the commands.
+func WithTimeouts(timeouts *cli.Timeouts) Option {
+	return func(s *Skaffold) {
+		s.timeouts = timeouts
+	}
+}
+
+// WithLogging configures where the invocations and the output of the commands are reported.
+func WithLogging(logging *cli.Logging) Option {
+	return func(s *Skaffold) {
+		s.logging = logging
+	}
+}
+
+func NewSkaffold(version, binDir, kubeConfigPath string, opts ...Option) *Skaffold {
+	s := &Skaffold{
 		version:        version,
 		name:           "skaffold",
 		binDir:         binDir,
 		kubeConfigPath: kubeConfigPath,
-		url:            fmt.Sprintf("https://storage.googleapis.com/skaffold/releases/v%s/skaffold-%s-%s", version, runtime.GOOS, runtime.GOARCH),
+		platform:       cli.HostPlatform(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.url = fmt.Sprintf("https://storage.googleapis.com/skaffold/releases/v%s/skaffold-%s-%s", version, s.platform.OS, s.platform.Arch)
+	return s
 }
 
 func (s *Skaffold) Name() string {
@@ -48,6 +103,47 @@ func (s *Skaffold) URL() string {
 	return s.url
 }
 
+func (s *Skaffold) Logging() *cli.Logging {
+	return s.logging
+}
+
+func (s *Skaffold) Timeouts() *cli.Timeouts {
+	return s.timeouts
+}
+
+func (s *Skaffold) Executor() cli.Executor {
+	return s.executor
+}
+
+func (s *Skaffold) DownloadConfig() *cli.DownloadConfig {
+	return s.download
+}
+
+func (s *Skaffold) SHA256() string {
+	return s.sha256
+}
+
+func (s *Skaffold) SHA256URL() string {
+	return s.url + ".sha256"
+}
+
+func (s *Skaffold) VersionArgs() []string {
+	return []string{"version"}
+}
+
+// ParseVersion parses the output of skaffold version, e.g. "v1.26.1".
+func (s *Skaffold) ParseVersion(stdout string) (string, error) {
+	version := strings.TrimSpace(stdout)
+	if version == "" || strings.ContainsAny(version, " \n") {
+		return "", fmt.Errorf("unexpected output of skaffold version: %q", stdout)
+	}
+	return strings.TrimPrefix(version, "v"), nil
+}
+
+func (s *Skaffold) Platform() cli.Platform {
+	return s.platform
+}
+
 func (s *Skaffold) Envs() []string {
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -61,12 +157,23 @@ func (s *Skaffold) Envs() []string {
 	}
 }
 
-// Execute If OutPut is necessary, use Capture. Execute uses os.Stderr.
+// Execute If OutPut is necessary, use Capture.
+// Execute reports the output as configured by WithLogging, or uses os.Stdout and os.Stderr.
 func (s *Skaffold) Execute(ctx context.Context, args []string) error {
-	return cli.Run(ctx, s, args, os.Stdout, os.Stderr)
+	return cli.Execute(ctx, s, args)
 }
 
 // Capture execute command with returning outs as string.
 func (s *Skaffold) Capture(ctx context.Context, args []string) (stdout string, stderr string, err error) {
 	return cli.Capture(ctx, s, args)
 }
+
+// Stream execute command with calling fn for every line of the outputs as it arrives.
+func (s *Skaffold) Stream(ctx context.Context, args []string, fn func(line cli.Line)) error {
+	return cli.Stream(ctx, s, args, fn)
+}
+
+// Start execute command in the background.
+func (s *Skaffold) Start(ctx context.Context, args []string, opts ...cli.ProcessOption) (*cli.Process, error) {
+	return cli.Start(ctx, s, args, opts...)
+}